@@ -0,0 +1,269 @@
+package build
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/css"
+	"github.com/kasperisager/pak/pkg/asset/html"
+	"github.com/kasperisager/pak/pkg/asset/html/ast"
+	"github.com/kasperisager/pak/pkg/asset/js"
+	"github.com/kasperisager/pak/pkg/asset/webmanifest"
+)
+
+type (
+	// An Analysis describes a graph as it looked before being compressed along
+	// with the outcome of compressing it, or the graph of a finished build.
+	Analysis struct {
+		Graph     *asset.Graph
+		Assets    []asset.Asset
+		Entries   []asset.Asset
+		Relations []Relation
+
+		// Locations holds, for every relation, the part of the source that it
+		// originates from, such as the <link> element or the @import rule.
+		Locations map[asset.Relation]string
+
+		// Partitions holds, for every asset, the entries that it is reached from.
+		// Assets reached from the same entries are in the same partition.
+		Partitions map[asset.Asset][]asset.Asset
+
+		// Merged holds, for every asset that was merged, the asset that it was
+		// merged into.
+		Merged map[asset.Asset]asset.Asset
+
+		// Origins holds, for every asset, the URL that it was read from, which
+		// differs from its URL once it has been renamed or vendored.
+		Origins map[asset.Asset]*url.URL
+
+		// The assets read from every URL, in the order of the assets.
+		urls map[string][]asset.Asset
+	}
+
+	Relation struct {
+		From     asset.Asset
+		To       asset.Asset
+		Relation asset.Relation
+	}
+)
+
+func Analyze(urls []*url.URL, options Options, sources Sources) (*Analysis, error) {
+	graph, entries, err := read(urls, options, sources, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[asset.Asset]*url.URL, graph.Size())
+
+	for _, asset := range graph.Assets() {
+		origins[asset] = asset.URL()
+	}
+
+	// Compressing the graph changes it, so analyse it as it was first.
+	analysis := analyze(graph, entries, origins)
+
+	analysis.Merged, err = compress(graph, entries, options.optimize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis, nil
+}
+
+// analyze describes a graph, ordering its assets by the URLs they were read
+// from. The graph of the analysis is a copy that later changes to the graph
+// do not affect.
+func analyze(graph *asset.Graph, entries []asset.Asset, origins map[asset.Asset]*url.URL) *Analysis {
+	assets := graph.Assets()
+
+	sort.SliceStable(assets, func(i, j int) bool {
+		a, b := origins[assets[i]].String(), origins[assets[j]].String()
+
+		if a != b {
+			return a < b
+		}
+
+		return assets[i].MediaType() < assets[j].MediaType()
+	})
+
+	order := make(map[asset.Asset]int, len(assets))
+
+	urls := make(map[string][]asset.Asset, len(assets))
+
+	for i, asset := range assets {
+		order[asset] = i
+
+		url := origins[asset].String()
+		urls[url] = append(urls[url], asset)
+	}
+
+	snapshot := asset.NewGraph()
+
+	for _, asset := range assets {
+		snapshot.Add(asset)
+	}
+
+	var relations []Relation
+
+	locations := make(map[asset.Relation]string)
+
+	for _, from := range assets {
+		edges, _ := graph.Outgoing(from)
+
+		for _, edge := range edges {
+			relation, to := edge.Relation, edge.Asset
+
+			relations = append(relations, Relation{from, to, relation})
+			snapshot.Relate(from, to, relation)
+			locations[relation] = Location(relation)
+		}
+	}
+
+	sort.SliceStable(relations, func(i, j int) bool {
+		a, b := relations[i], relations[j]
+
+		if a.From != b.From {
+			return order[a.From] < order[b.From]
+		}
+
+		if a.To != b.To {
+			return order[a.To] < order[b.To]
+		}
+
+		return Kind(a.Relation) < Kind(b.Relation)
+	})
+
+	partitions := make(map[asset.Asset][]asset.Asset, len(assets))
+
+	for _, entry := range entries {
+		reach(graph, partitions, entry, entry, make(map[asset.Asset]bool))
+	}
+
+	return &Analysis{
+		Graph:      snapshot,
+		Assets:     assets,
+		Entries:    entries,
+		Relations:  relations,
+		Locations:  locations,
+		Partitions: partitions,
+		Origins:    origins,
+		urls:       urls,
+	}
+}
+
+// ByURL returns the assets that were read from a URL. This can be several
+// assets as embedded assets share the URL of the asset that they are embedded
+// in.
+func (a *Analysis) ByURL(url *url.URL) []asset.Asset {
+	return a.urls[url.String()]
+}
+
+// Kind describes how one asset relates to another, such as through a CSS
+// @import or an HTML <script> element.
+func Kind(relation asset.Relation) string {
+	switch relation := relation.(type) {
+	case *css.Reference:
+		if relation.Declaration != nil {
+			return "url()"
+		}
+
+		return "@import"
+
+	case *html.Reference:
+		if relation.Element != nil {
+			return relation.Element.Name
+		}
+
+		return "reference"
+
+	case *html.Embed:
+		return "embed"
+
+	case *js.Reference:
+		return "import"
+
+	case *webmanifest.Reference:
+		return "webmanifest"
+
+	default:
+		return "reference"
+	}
+}
+
+// Location renders the part of the source that a relation originates from.
+func Location(relation asset.Relation) string {
+	switch relation := relation.(type) {
+	case *css.Reference:
+		if relation.Declaration != nil {
+			return fmt.Sprintf("%s: url(%s)", relation.Declaration.Name, relation.URL())
+		}
+
+		return fmt.Sprintf("@import %q", relation.URL())
+
+	case *html.Reference:
+		if relation.Element != nil {
+			return tag(relation.Element)
+		}
+
+	case *html.Embed:
+		if relation.Element != nil {
+			return tag(relation.Element)
+		}
+
+	case *js.Reference:
+		return fmt.Sprintf("import %q", relation.URL())
+	}
+
+	return Kind(relation)
+}
+
+// Emitted reports whether the analysed asset ends up as a file of its own
+// rather than being merged into another asset.
+func (a *Analysis) Emitted(asset asset.Asset) bool {
+	_, merged := a.Merged[asset]
+
+	return !merged && !asset.URL().IsAbs()
+}
+
+func reach(
+	graph *asset.Graph,
+	partitions map[asset.Asset][]asset.Asset,
+	entry asset.Asset,
+	asset asset.Asset,
+	visited map[asset.Asset]bool,
+) {
+	if visited[asset] {
+		return
+	}
+
+	visited[asset] = true
+
+	partitions[asset] = append(partitions[asset], entry)
+
+	edges, _ := graph.Outgoing(asset)
+
+	for _, edge := range edges {
+		related := edge.Asset
+
+		reach(graph, partitions, entry, related, visited)
+	}
+}
+
+func tag(element *ast.Element) string {
+	var b strings.Builder
+
+	b.WriteString("<" + element.Name)
+
+	for _, attribute := range element.Attributes {
+		fmt.Fprintf(&b, " %s=%q", attribute.Name, attribute.Value)
+	}
+
+	b.WriteString(">")
+
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package build
+
+import (
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><script type="module" src="app.js"></script></head><body></body></html>`,
+		"about.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css":  `@import "base.css";body{background:url(bg.png)}`,
+		"css/base.css": `a{color:red}`,
+		"css/bg.png":   `png`,
+		"app.js":       `import "./util.js";`,
+		"util.js":      ``,
+	})
+
+	defer os.RemoveAll(root)
+
+	urls := []*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}
+
+	analysis, err := Analyze(urls, Options{Root: root, Jobs: 1, Optimize: 1}, make(Sources))
+	assert.Nil(t, err)
+
+	assets := make(map[string]int)
+
+	for i, asset := range analysis.Assets {
+		assets[asset.URL().Path+" "+asset.MediaType()] = i
+	}
+
+	lookup := func(path string, mediaType string) int {
+		i, ok := assets[path+" "+mediaType]
+		assert.True(t, ok, path)
+		return i
+	}
+
+	index := analysis.Assets[lookup("/index.html", "text/html")]
+	about := analysis.Assets[lookup("/about.html", "text/html")]
+	styles := analysis.Assets[lookup("/css/app.css", "text/css")]
+	base := analysis.Assets[lookup("/css/base.css", "text/css")]
+	script := analysis.Assets[lookup("/app.js", "application/javascript")]
+	util := analysis.Assets[lookup("/util.js", "application/javascript")]
+
+	var kinds []string
+
+	for _, relation := range analysis.Relations {
+		kinds = append(kinds, relation.From.URL().Path+" "+Kind(relation.Relation)+" "+relation.To.URL().Path)
+	}
+
+	assert.Contains(t, kinds, "/index.html link /css/app.css")
+	assert.Contains(t, kinds, "/index.html script /app.js")
+	assert.Contains(t, kinds, "/css/app.css @import /css/base.css")
+	assert.Contains(t, kinds, "/css/app.css url() /css/bg.png")
+	assert.Contains(t, kinds, "/app.js import /util.js")
+
+	assert.Len(t, analysis.Partitions[index], 1)
+	assert.Len(t, analysis.Partitions[styles], 2)
+	assert.Len(t, analysis.Partitions[script], 1)
+
+	assert.Equal(t, styles, analysis.Merged[base])
+	assert.NotContains(t, analysis.Merged, util)
+	assert.True(t, analysis.Emitted(util))
+	assert.False(t, analysis.Emitted(base))
+	assert.True(t, analysis.Emitted(styles))
+	assert.True(t, analysis.Emitted(about))
+}
@@ -1,6 +1,7 @@
 package build
 
 import (
+	"flag"
 	"fmt"
 	"hash"
 	"hash/fnv"
@@ -10,8 +11,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
 	"github.com/kasperisager/pak/pkg/asset"
 	"github.com/kasperisager/pak/pkg/cli"
 
@@ -23,52 +27,353 @@ import (
 	"github.com/kasperisager/pak/pkg/asset/webmanifest"
 )
 
+// Version identifies the build of pak and is part of the key of every entry in
+// the build cache, so it must be bumped whenever the output of parsing changes.
+const Version = "0.1.0"
+
+type (
+	Options struct {
+		Root       string
+		Out        string
+		Vendor     string
+		Hash       bool
+		Jobs       int
+		Cache      string
+		NoCache    bool
+		Lock       string
+		UpdateLock bool
+		Offline    bool
+		Timeout    time.Duration
+		Retries    int
+		Redirects  int
+		Allow      string
+		Manifest   string
+		Stats      string
+		MaxErrors  int
+		Optimize   int
+		PublicURL  string
+		Integrity  bool
+		CSP        string
+		Extract    bool
+		Inline     int
+		MediaTypes map[string]*MediaTypeOptions
+
+		// VerifyReproducible builds twice and fails if the outputs differ.
+		VerifyReproducible bool
+
+		// Report, if set, receives the events of builds.
+		Report Reporter
+	}
+
+	// MediaTypeOptions override options for files of a single media type.
+	MediaTypeOptions struct {
+		// Optimize is the optimization level of files of the media type, which
+		// decides whether other files are merged into them.
+		Optimize *int `json:"optimize"`
+
+		// Hash decides whether to add content hashes to the names of files of
+		// the media type.
+		Hash *bool `json:"hash"`
+	}
+
+	// An instance is the asset of a node as used by a single build, along with
+	// its relations in the same order as those of the node.
+	instance struct {
+		asset      asset.Asset
+		references []asset.Reference
+		embeds     []asset.Embed
+	}
+
+	// A Result is a compiled graph along with the entries it was compiled from
+	// and the URLs that its assets had before being vendored or renamed.
+	Result struct {
+		Graph   *asset.Graph
+		Entries []asset.Asset
+		Origins map[asset.Asset]*url.URL
+
+		// Policies are the Content Security Policies of pages, if computed.
+		Policies map[asset.Asset]string
+	}
+)
+
+func (options *Options) Define(flag *flag.FlagSet) {
+	flag.StringVar(&options.Root, "root", "", "The root directory of entry files")
+	flag.StringVar(&options.Vendor, "vendor", "", "The directory, relative to the output, to copy external files to, or nothing to leave them external")
+	flag.BoolVar(&options.Hash, "hash", false, "Add content hashes to the names of non-entry files")
+	flag.IntVar(&options.Optimize, "O", 1, "The optimization level, either 0 to leave files as they are or 1 to merge files that are always loaded together")
+	flag.BoolVar(&options.Integrity, "sri", false, "Add integrity attributes to scripts and stylesheets")
+	flag.IntVar(&options.Inline, "inline", 0, "Inline stylesheets, scripts and images of at most this many bytes, or 0 to inline nothing")
+	flag.BoolVar(&options.Extract, "extract", false, "Move inline styles and scripts into files of their own")
+	flag.StringVar(&options.CSP, "csp", "", "Generate a Content Security Policy for every page, either as a meta tag or in a _headers file of the output")
+	flag.StringVar(&options.PublicURL, "public-url", "", "The base URL to reference non-HTML files at, such as https://cdn.example.com/")
+	flag.IntVar(&options.Jobs, "j", runtime.NumCPU(), "The number of files to read and parse concurrently")
+	flag.StringVar(&options.Cache, "cache", cache.Dir, "The directory of the build cache")
+	flag.BoolVar(&options.NoCache, "no-cache", false, "Do not read from or write to the build cache")
+	flag.StringVar(&options.Lock, "lock", "pak.lock", "The lockfile of external files")
+	flag.BoolVar(&options.UpdateLock, "update-lock", false, "Update the lockfile if external files have changed")
+	flag.BoolVar(&options.Offline, "offline", false, "Read external files from the build cache using the lockfile")
+	flag.DurationVar(&options.Timeout, "timeout", 30*time.Second, "The time to wait for an external file")
+	flag.IntVar(&options.Retries, "retries", 2, "The number of times to retry fetching an external file")
+	flag.IntVar(&options.Redirects, "max-redirects", 5, "The number of redirects to follow when fetching an external file")
+	flag.IntVar(&options.MaxErrors, "max-errors", 0, "The number of errors to report before stopping, or 0 to report all errors")
+	flag.StringVar(&options.Allow, "allow", "", "A comma-separated list of hosts to allow fetching external files from, such as example.com or *.example.com")
+}
+
 func Command(cmd *cli.Command) {
 	flag := cmd.Flag()
 
-	var (
-		out  = flag.String("o", "dist", "The directory to write files to")
-		root = flag.String("root", "", "The root directory of entry files")
-		_    = flag.String("vendor", "vendor", "The vendor directory of external files")
-	)
+	var options Options
+
+	options.Define(flag)
+
+	flag.StringVar(&options.Out, "o", "dist", "The directory to write files to")
+	flag.StringVar(&options.Manifest, "manifest", "", "The file, relative to the output, to write a manifest of the output to")
+	flag.StringVar(&options.Stats, "stats", "", "The file, relative to the output, to write a description of the output to for comparing builds with pak diff")
+	flag.BoolVar(&options.VerifyReproducible, "verify-reproducible", false, "Build twice and fail if the outputs differ")
+
+	format := flag.String("format", "text", "The format to report progress and errors in, either text or json")
+
+	config := flag.String("config", DefaultConfig, "The project configuration file to read")
 
 	cmd.Usage("[flags] [entry files]")
 
 	cmd.HandleFunc(func(filenames []string) {
-		root, out := *root, *out
+		switch *format {
+		case "text":
+			if err := run(filenames, &options, flag, *config); err != nil {
+				cmd.Fatal(err)
+			}
 
-		var err error
+		case "json":
+			start := time.Now()
+
+			report := NewJSONReporter(os.Stdout)
+
+			files := 0
+
+			options.Report = func(event Event) {
+				if event.Type == WriteEvent {
+					files++
+				}
+
+				report(event)
+			}
 
-		if root == "" {
-			root, err = computeRoot(filenames)
+			err := run(filenames, &options, flag, *config)
 
 			if err != nil {
-				cmd.Fatal(err)
+				for _, event := range Diagnostics(err, options.Root) {
+					report(event)
+				}
+
+				cli.ExitCode(1)
 			}
+
+			report(Summary(err, files, start))
+
+			cli.Exit()
+
+		default:
+			cmd.Fatalf("unknown format %q", *format)
 		}
+	})
+}
 
-		urls := make([]*url.URL, len(filenames))
+// run builds the entries given on the command line, or those of the project
+// configuration if there are none, reporting the start of the build once the
+// entries are known. The options are updated with those of the configuration
+// and the root directory that the build ends up using.
+func run(filenames []string, options *Options, flags *flag.FlagSet, path string) error {
+	config, err := ReadConfig(path)
 
-		for i, filename := range filenames {
-			url, err := resource(filename, root)
+	if config != nil {
+		filenames = config.Apply(options, flags, filenames)
+	}
 
-			if err != nil {
-				cmd.Fatal(err)
-			}
+	if options.Report != nil {
+		options.Report(Event{Type: StartEvent, Version: Version, Entries: filenames})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return build(filenames, options)
+}
+
+func build(filenames []string, options *Options) error {
+	if len(filenames) == 0 {
+		return fmt.Errorf("no entry files given on the command line or in %s", DefaultConfig)
+	}
+
+	urls, err := Entries(filenames, options)
+
+	if err != nil {
+		return err
+	}
+
+	sources := make(Sources)
+
+	result, err := Compile(urls, *options, sources)
+
+	if err != nil {
+		return err
+	}
+
+	if options.VerifyReproducible {
+		// The second build reuses the files fetched by the first so that only
+		// the build itself is compared, and reports nothing of its own.
+		again := *options
+		again.Report = nil
+
+		other, err := Compile(urls, again, sources)
+
+		if err != nil {
+			return err
+		}
+
+		if err := reproducible(result, other); err != nil {
+			return err
+		}
+	}
+
+	return Write(result, *options)
+}
+
+func (options Options) optimize(mediaType string) int {
+	if override, ok := options.MediaTypes[mediaType]; ok && override.Optimize != nil {
+		return *override.Optimize
+	}
+
+	return options.Optimize
+}
+
+func (options Options) hash(mediaType string) bool {
+	if override, ok := options.MediaTypes[mediaType]; ok && override.Hash != nil {
+		return *override.Hash
+	}
 
-			urls[i] = url
+	return options.Hash
+}
+
+func Entries(filenames []string, options *Options) ([]*url.URL, error) {
+	var err error
+
+	if options.Root == "" {
+		options.Root, err = computeRoot(filenames)
+
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	urls := make([]*url.URL, len(filenames))
 
-		graph, err := read(urls, root)
+	for i, filename := range filenames {
+		url, err := resource(filename, options.Root)
 
 		if err != nil {
-			cmd.Fatal(err)
+			return nil, err
 		}
 
-		if err = write(graph, out); err != nil {
-			cmd.Fatal(err)
+		urls[i] = url
+	}
+
+	return urls, nil
+}
+
+func Compile(urls []*url.URL, options Options, sources Sources) (*Result, error) {
+	return compile(urls, options, sources, nil)
+}
+
+// compile builds the entries, reading the assets that were kept by a session
+// from an earlier build only if they have changed since.
+func compile(urls []*url.URL, options Options, sources Sources, session *Session) (*Result, error) {
+	switch options.CSP {
+	case "", "meta", "headers":
+	default:
+		return nil, fmt.Errorf("unknown csp %q, expected meta or headers", options.CSP)
+	}
+
+	graph, entries, err := read(urls, options, sources, session)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Extract {
+		extract(graph)
+	}
+
+	if _, err := compress(graph, entries, options.optimize); err != nil {
+		return nil, err
+	}
+
+	if options.Inline > 0 {
+		if err := inline(graph, entries, options.Inline); err != nil {
+			return nil, err
 		}
-	})
+	}
+
+	origins := make(map[asset.Asset]*url.URL, graph.Size())
+
+	for _, asset := range graph.Assets() {
+		origins[asset] = asset.URL()
+	}
+
+	if options.Vendor != "" {
+		vendor(graph, options.Vendor)
+	}
+
+	public, err := publicURL(options.PublicURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint(graph, entries, options.hash, public)
+
+	sign(graph, sources, options.Integrity)
+
+	var policies map[asset.Asset]string
+
+	if options.CSP != "" {
+		// The policies must be computed last as they cover the final contents
+		// of inline scripts and styles.
+		policies = computePolicies(graph)
+
+		if options.CSP == "meta" {
+			for page, policy := range policies {
+				embedPolicy(page, policy)
+			}
+		}
+	}
+
+	return &Result{graph, entries, origins, policies}, nil
+}
+
+func Write(result *Result, options Options) error {
+	if err := write(result.Graph, options.Out, options.Report); err != nil {
+		return err
+	}
+
+	if options.CSP == "headers" {
+		if err := writeHeaders(result.Policies, options.Out); err != nil {
+			return err
+		}
+	}
+
+	if options.Manifest != "" {
+		if err := writeManifest(result, options.Out, options.Manifest); err != nil {
+			return err
+		}
+	}
+
+	if options.Stats != "" {
+		return writeStats(result, options.Out, options.Stats)
+	}
+
+	return nil
 }
 
 func computeRoot(filenames []string) (string, error) {
@@ -124,34 +429,102 @@ func filename(url *url.URL, base string) string {
 	return filepath.FromSlash(filename)
 }
 
-func read(urls []*url.URL, root string) (*asset.Graph, error) {
+func read(
+	urls []*url.URL,
+	options Options,
+	sources Sources,
+	session *Session,
+) (*asset.Graph, []asset.Asset, error) {
+	var store *cache.Cache
+
+	if !options.NoCache && options.Cache != "" {
+		store = cache.Open(options.Cache)
+	}
+
+	var lockfile *lockfile
+
+	if options.Lock != "" {
+		var contents *cache.Cache
+
+		if options.Cache != "" {
+			contents = cache.Open(options.Cache)
+		}
+
+		var err error
+
+		lockfile, err = openLockfile(options.Lock, contents, options.UpdateLock, options.Offline)
+
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if options.Offline {
+		return nil, nil, fmt.Errorf("offline builds require a lockfile")
+	}
+
+	fetcher := newFetcher(options, store)
+
+	loader := newLoader(options.Root, sources, store, fetcher, lockfile, options.Jobs, options.Report)
+
+	if session != nil {
+		loader.kept, loader.stale = session.nodes, session.stale
+	}
+
+	nodes := make([]*node, len(urls))
+
+	for i, url := range urls {
+		nodes[i] = loader.resolve(url, nil)
+	}
+
 	graph := asset.NewGraph()
 
 	entries := make([]asset.Asset, len(urls))
 
-	for i, url := range urls {
-		resolved, err := resolve(url, root, graph, nil)
+	errs := &errorList{limit: options.MaxErrors}
 
-		if err != nil {
-			return nil, err
+	failed := make(map[*node]bool)
+
+	instances := make(map[*node]*instance)
+
+	for i, node := range nodes {
+		if !check(node, errs, failed) {
+			continue
 		}
 
-		graph.Add(resolved)
+		instance, ok := instances[node]
 
-		err = collect(resolved, url, root, graph)
+		if !ok {
+			instance = instantiate(node, session != nil)
+			instances[node] = instance
 
-		if err != nil {
-			return nil, err
+			graph.Add(instance.asset)
+
+			collect(graph, node, instances, session != nil, errs, failed, nil)
 		}
 
-		entries[i] = resolved
+		entries[i] = instance.asset
 	}
 
-	if err := compress(graph, entries); err != nil {
-		return nil, err
+	// The walk stops early if too many errors are found, leaving assets that
+	// are still being loaded.
+	loader.stop()
+
+	if session != nil {
+		session.keep(nodes)
 	}
 
-	return graph, nil
+	verify(graph, sources, errs)
+
+	if err := errs.err(); err != nil {
+		return nil, nil, err
+	}
+
+	if lockfile != nil {
+		if err := lockfile.save(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return graph, entries, nil
 }
 
 func parse(url *url.URL, data []byte, mediaType string, flags asset.Flags) (asset.Asset, error) {
@@ -176,106 +549,190 @@ func parse(url *url.URL, data []byte, mediaType string, flags asset.Flags) (asse
 	}
 }
 
-func resolve(
-	url *url.URL,
-	root string,
-	graph *asset.Graph,
-	flags asset.Flags,
-) (asset.Asset, error) {
-	if asset, ok := graph.Lookup(asset.ByURL(url)); ok {
-		return asset, nil
-	}
-
-	mediaType, data, err := fetch(url, root, flags)
+func decode(url *url.URL, data []byte, mediaType string) (asset.Asset, error) {
+	switch mediaType {
+	case css.MediaType:
+		return css.FromBinary(url, data)
 
-	if err != nil {
-		return nil, err
-	}
+	case html.MediaType:
+		return html.FromBinary(url, data)
 
-	resolved, err := parse(url, data, mediaType, flags)
+	case js.MediaType:
+		return js.FromBinary(url, data)
 
-	if err != nil {
-		return nil, err
+	default:
+		return nil, fmt.Errorf("%s: cannot decode %s", url, mediaType)
 	}
-
-	return resolved, nil
 }
 
+// collect adds the assets that a node relates to to a graph. Assets that
+// failed to load are left out and their errors gathered, reporting each only
+// once, so that a single build surfaces as many errors as possible. Each node
+// is only walked once, when it is first instantiated, and the path of nodes
+// leading to the current node is kept so that cycles can be found.
 func collect(
-	asset asset.Asset,
-	url *url.URL,
-	root string,
 	graph *asset.Graph,
-) error {
-	for _, reference := range asset.References() {
-		url := asset.URL().ResolveReference(reference.URL())
-		flags := reference.Flags()
+	node *node,
+	instances map[*node]*instance,
+	clone bool,
+	errs *errorList,
+	failed map[*node]bool,
+	path []*node,
+) {
+	path = append(path, node)
 
-		referenced, err := resolve(url, root, graph, flags)
+	from := instances[node]
 
-		if err != nil {
-			return err
+	for i, reference := range from.references {
+		referenced := node.referenced[i]
+
+		if !check(referenced, errs, failed) {
+			continue
 		}
 
-		graph.Add(referenced)
-		graph.Relate(asset, referenced, reference)
+		// Modules may import each other, but a stylesheet that ends up
+		// importing itself can never be merged.
+		if cycle := cycle(path, referenced); cycle != nil && imports(reference) {
+			names := make([]string, len(cycle))
 
-		err = collect(referenced, url, root, graph)
+			for i, found := range cycle {
+				names[i] = found.url.String()
+			}
 
-		if err != nil {
-			return err
+			errs.add(node.url, fmt.Errorf("import cycle: %s", strings.Join(names, " -> ")))
+
+			continue
+		}
+
+		to, ok := instances[referenced]
+
+		if !ok {
+			to = instantiate(referenced, clone)
+			instances[referenced] = to
+
+			graph.Add(to.asset)
+		}
+
+		graph.Relate(from.asset, to.asset, reference)
+
+		if !ok {
+			collect(graph, referenced, instances, clone, errs, failed, path)
 		}
 	}
 
-	for _, embed := range asset.Embeds() {
-		data := embed.Data()
-		mediaType := embed.MediaType()
-		flags := embed.Flags()
+	for i, embed := range from.embeds {
+		embedded := node.embedded[i]
 
-		embedded, err := parse(url, data, mediaType, flags)
+		if !check(embedded, errs, failed) {
+			continue
+		}
 
-		if err != nil {
-			return err
+		to, ok := instances[embedded]
+
+		if !ok {
+			to = instantiate(embedded, clone)
+			instances[embedded] = to
+
+			graph.Add(to.asset)
 		}
 
-		graph.Add(embedded)
-		graph.Relate(asset, embedded, embed)
+		graph.Relate(from.asset, to.asset, embed)
 
-		err = collect(embedded, url, root, graph)
+		if !ok {
+			collect(graph, embedded, instances, clone, errs, failed, path)
+		}
+	}
+}
 
-		if err != nil {
-			return err
+// instantiate returns the asset of a node as used by a single build. When
+// nodes are kept between builds, the build is given a copy of the asset so
+// that the changes it makes, such as merges and rewrites, are not carried over
+// to the next build.
+func instantiate(node *node, clone bool) *instance {
+	if cloner, ok := node.asset.(asset.Cloner); ok && clone {
+		clone := cloner.Clone()
+		return &instance{clone, clone.References(), clone.Embeds()}
+	}
+
+	return &instance{node.asset, node.references, node.embeds}
+}
+
+// cycle returns the cycle that relating the last node of a path to another
+// node would close, starting and ending at the other node, or nil if the
+// other node is not on the path.
+func cycle(path []*node, to *node) []*node {
+	for i, found := range path {
+		if found == to {
+			return append(append([]*node{}, path[i:]...), to)
 		}
 	}
 
 	return nil
 }
 
-func compress(graph *asset.Graph, entries []asset.Asset) error {
+// imports reports whether a reference is an @import rule, which is the only
+// kind of stylesheet reference that has a rule.
+func imports(reference asset.Reference) bool {
+	stylesheet, ok := reference.(*css.Reference)
+	return ok && stylesheet.Rule != nil
+}
+
+// check waits for a node and reports whether it loaded successfully and the
+// build should continue past it.
+func check(node *node, errs *errorList, failed map[*node]bool) bool {
+	if errs.full {
+		return false
+	}
+
+	<-node.done
+
+	if node.err != nil {
+		if !failed[node] {
+			failed[node] = true
+			errs.add(node.url, node.err)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// compress merges assets into the assets that reference them when both are
+// reached from the same entries and the optimization level of the referencing
+// asset allows it, returning the asset each merged asset was merged into.
+func compress(
+	graph *asset.Graph,
+	entries []asset.Asset,
+	optimize func(mediaType string) int,
+) (map[asset.Asset]asset.Asset, error) {
 	partitions, err := partition(graph, entries)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	visited := make(map[asset.Asset]bool)
+	merged := make(map[asset.Asset]asset.Asset)
 
 	for _, entry := range entries {
-		err := merge(graph, partitions, entry, visited)
+		err := merge(graph, partitions, optimize, entry, visited, merged)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return merged, nil
 }
 
 func merge(
 	graph *asset.Graph,
 	partitions map[asset.Asset]string,
+	optimize func(mediaType string) int,
 	target asset.Asset,
 	visited map[asset.Asset]bool,
+	merged map[asset.Asset]asset.Asset,
 ) error {
 	if visited[target] {
 		return nil
@@ -285,15 +742,23 @@ func merge(
 
 	edges, _ := graph.Outgoing(target)
 
-	for _, related := range edges {
-		err := merge(graph, partitions, related, visited)
+	for _, edge := range edges {
+		relation, related := edge.Relation, edge.Asset
+
+		err := merge(graph, partitions, optimize, related, visited, merged)
 
 		if err != nil {
 			return err
 		}
 
-		if partitions[target] == partitions[related] {
-			graph.Merge(target, related)
+		// Embedded assets are part of the asset they are embedded in and so
+		// must be merged back into it regardless of the optimization level.
+		_, referenced := relation.(asset.Reference)
+
+		if partitions[target] == partitions[related] && (!referenced || optimize(target.MediaType()) > 0) {
+			if graph.Merge(target, related) {
+				merged[related] = target
+			}
 		}
 	}
 
@@ -354,7 +819,9 @@ func mark(
 
 	edges, _ := graph.Outgoing(asset)
 
-	for _, related := range edges {
+	for _, edge := range edges {
+		related := edge.Asset
+
 		err := mark(graph, hashes, related, data, visited)
 
 		if err != nil {
@@ -366,36 +833,32 @@ func mark(
 }
 
 func fetch(url *url.URL, root string, flags asset.Flags) (mediaType string, data []byte, err error) {
-	if url.IsAbs() {
-		response, err := http.Get(url.String())
-
-		if err != nil {
-			return "", nil, err
-		}
+	data, err = ioutil.ReadFile(filename(url, root))
 
-		defer response.Body.Close()
+	if err != nil {
+		return "", nil, err
+	}
 
-		mediaType = response.Header.Get("content-type")
+	return detect(url, data, flags, ""), data, nil
+}
 
-		data, err = ioutil.ReadAll(response.Body)
-	} else {
-		data, err = ioutil.ReadFile(filename(url, root))
+func detect(url *url.URL, data []byte, flags asset.Flags, header string) string {
+	if flags.Has("mediaType") {
+		return flags.Get("mediaType").(string)
+	}
 
-		if flags.Has("mediaType") {
-			mediaType = flags.Get("mediaType").(string)
-		} else {
-			mediaType = asset.MediaTypeByURL(url)
+	if mediaType := asset.ParseMediaType(header); mediaType != "" {
+		return mediaType
+	}
 
-			if mediaType == "" {
-				mediaType = http.DetectContentType(data)
-			}
-		}
+	if mediaType := asset.MediaTypeByURL(url); mediaType != "" {
+		return mediaType
 	}
 
-	return mediaType, data, err
+	return asset.ParseMediaType(http.DetectContentType(data))
 }
 
-func write(graph *asset.Graph, out string) error {
+func write(graph *asset.Graph, out string, report Reporter) error {
 	for _, asset := range graph.Assets() {
 		var target string
 
@@ -411,9 +874,21 @@ func write(graph *asset.Graph, out string) error {
 			return err
 		}
 
-		if err := ioutil.WriteFile(target, asset.Data(), 0644); err != nil {
+		data := asset.Data()
+
+		if err := ioutil.WriteFile(target, data, 0644); err != nil {
 			return err
 		}
+
+		if report != nil {
+			report(Event{
+				Type:      WriteEvent,
+				URL:       url.String(),
+				MediaType: asset.MediaType(),
+				Size:      intPtr(len(data)),
+				File:      filepath.ToSlash(target),
+			})
+		}
 	}
 
 	return nil
@@ -0,0 +1,131 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func site(t testing.TB, files map[string]string) string {
+	root, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	}
+
+	return root
+}
+
+func TestCycles(t *testing.T) {
+	var tests = []struct {
+		files map[string]string
+		err   string
+	}{
+		{
+			map[string]string{
+				"index.html": `<!doctype html><html><head><script type="module" src="a.js"></script></head><body></body></html>`,
+				"a.js":       `import "./b.js";`,
+				"b.js":       `import "./a.js"; import "./b.js";`,
+			},
+			"",
+		},
+		{
+			map[string]string{
+				"index.html": `<!doctype html><html><head><link rel="preload" href="about.html"></head><body></body></html>`,
+				"about.html": `<!doctype html><html><head><link rel="preload" href="index.html"></head><body></body></html>`,
+			},
+			"",
+		},
+		{
+			map[string]string{
+				"index.html": `<!doctype html><html><head><link rel="stylesheet" href="a.css"></head><body></body></html>`,
+				"a.css":      `@import "b.css"; a{color:red}`,
+				"b.css":      `@import "c.css"; b{color:red}`,
+				"c.css":      `@import "a.css"; c{color:red}`,
+			},
+			"/c.css: import cycle: /a.css -> /b.css -> /c.css -> /a.css",
+		},
+		{
+			map[string]string{
+				"index.html": `<!doctype html><html><head><style>@import "a.css";</style></head><body></body></html>`,
+				"a.css":      `@import "a.css";`,
+			},
+			"/a.css: import cycle: /a.css -> /a.css",
+		},
+	}
+
+	for _, test := range tests {
+		root := site(t, test.files)
+
+		options := Options{Root: root, Jobs: 1, Optimize: 1}
+
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+		os.RemoveAll(root)
+
+		if test.err != "" {
+			if assert.NotNil(t, err, test.err) {
+				assert.Equal(t, test.err, err.Error())
+			}
+
+			continue
+		}
+
+		if assert.Nil(t, err) {
+			assert.Equal(t, len(test.files), result.Graph.Size())
+		}
+	}
+}
+
+// BenchmarkCompile builds a site of 100 pages that each link 100 stylesheets
+// of their own, for a graph of about 10,000 assets.
+func BenchmarkCompile(b *testing.B) {
+	files := make(map[string]string)
+
+	for i := 0; i < 100; i++ {
+		var page strings.Builder
+
+		page.WriteString(`<!doctype html><html><head>`)
+
+		for j := 0; j < 100; j++ {
+			fmt.Fprintf(&page, `<link rel="stylesheet" href="css/%d/%d.css">`, i, j)
+
+			files[fmt.Sprintf("css/%d/%d.css", i, j)] = fmt.Sprintf(`.a%d{background:url(../../img/%d.png)}`, j, i)
+		}
+
+		page.WriteString(`</head><body></body></html>`)
+
+		files[fmt.Sprintf("%d.html", i)] = page.String()
+		files[fmt.Sprintf("img/%d.png", i)] = "png"
+	}
+
+	root := site(b, files)
+
+	defer os.RemoveAll(root)
+
+	urls := make([]*url.URL, 100)
+
+	for i := range urls {
+		urls[i] = &url.URL{Path: fmt.Sprintf("/%d.html", i)}
+	}
+
+	options := Options{Root: root, Jobs: 8, Hash: true}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Compile(urls, options, make(Sources)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
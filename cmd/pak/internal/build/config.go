@@ -0,0 +1,159 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultConfig is the project configuration file that is read when no other
+// file is given.
+const DefaultConfig = "pak.json"
+
+// A Config is a project configuration file. Paths in the file are relative to
+// the directory of the file.
+type Config struct {
+	Entries    []string                     `json:"entries"`
+	Root       *string                      `json:"root"`
+	Out        *string                      `json:"out"`
+	Vendor     *string                      `json:"vendor"`
+	Allow      []string                     `json:"allow"`
+	PublicURL  *string                      `json:"publicUrl"`
+	Integrity  *bool                        `json:"sri"`
+	CSP        *string                      `json:"csp"`
+	Extract    *bool                        `json:"extract"`
+	Inline     *int                         `json:"inline"`
+	Optimize   *int                         `json:"optimize"`
+	MediaTypes map[string]*MediaTypeOptions `json:"mediaTypes"`
+
+	dir string
+}
+
+// ReadConfig reads a project configuration file, returning nil if the file is
+// the default file and does not exist.
+func ReadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		if os.IsNotExist(err) && path == DefaultConfig {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	config := &Config{dir: filepath.Dir(path)}
+
+	if err := decoder.Decode(config); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field ") {
+			return nil, fmt.Errorf("%s: unknown key %s", path, strings.TrimPrefix(err.Error(), "json: unknown field "))
+		}
+
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("%s: unexpected data after configuration", path)
+	}
+
+	if config.Optimize != nil && (*config.Optimize < 0 || *config.Optimize > 1) {
+		return nil, fmt.Errorf("%s: optimize must be 0 or 1", path)
+	}
+
+	if config.CSP != nil && *config.CSP != "" && *config.CSP != "meta" && *config.CSP != "headers" {
+		return nil, fmt.Errorf("%s: csp must be meta or headers", path)
+	}
+
+	for mediaType, options := range config.MediaTypes {
+		if options == nil {
+			return nil, fmt.Errorf("%s: mediaTypes: %s must be an object", path, mediaType)
+		}
+
+		if options.Optimize != nil && (*options.Optimize < 0 || *options.Optimize > 1) {
+			return nil, fmt.Errorf("%s: mediaTypes: %s: optimize must be 0 or 1", path, mediaType)
+		}
+	}
+
+	return config, nil
+}
+
+// Apply sets the options of a configuration that have not been set by the
+// flags of a flag set, which must have been parsed, and returns the entries
+// to build if none were given on the command line.
+func (c *Config) Apply(options *Options, flags *flag.FlagSet, filenames []string) []string {
+	set := make(map[string]bool)
+
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if c.Root != nil && !set["root"] {
+		options.Root = c.path(*c.Root)
+	}
+
+	if c.Out != nil && !set["o"] {
+		options.Out = c.path(*c.Out)
+	}
+
+	if c.Vendor != nil && !set["vendor"] {
+		options.Vendor = *c.Vendor
+	}
+
+	if c.Allow != nil && !set["allow"] {
+		options.Allow = strings.Join(c.Allow, ",")
+	}
+
+	if c.PublicURL != nil && !set["public-url"] {
+		options.PublicURL = *c.PublicURL
+	}
+
+	if c.Integrity != nil && !set["sri"] {
+		options.Integrity = *c.Integrity
+	}
+
+	if c.CSP != nil && !set["csp"] {
+		options.CSP = *c.CSP
+	}
+
+	if c.Extract != nil && !set["extract"] {
+		options.Extract = *c.Extract
+	}
+
+	if c.Inline != nil && !set["inline"] {
+		options.Inline = *c.Inline
+	}
+
+	if c.Optimize != nil && !set["O"] {
+		options.Optimize = *c.Optimize
+	}
+
+	options.MediaTypes = c.MediaTypes
+
+	if len(filenames) > 0 {
+		return filenames
+	}
+
+	entries := make([]string, len(c.Entries))
+
+	for i, entry := range c.Entries {
+		entries[i] = c.path(entry)
+	}
+
+	return entries
+}
+
+func (c *Config) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(c.dir, filepath.FromSlash(name))
+}
@@ -0,0 +1,120 @@
+package build
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfig(t *testing.T) {
+	var tests = []struct {
+		config string
+		err    string
+	}{
+		{`{"entries":["index.html"],"out":"public"}`, ""},
+		{`{"mediaTypes":{"text/css":{"optimize":0,"hash":true}}}`, ""},
+		{`{"output":"public"}`, `unknown key "output"`},
+		{`{"mediaTypes":{"text/css":{"optimise":0}}}`, `unknown key "optimise"`},
+		{`{"optimize":2}`, "optimize must be 0 or 1"},
+		{`{"entries":"index.html"}`, "cannot unmarshal"},
+	}
+
+	for _, test := range tests {
+		root := site(t, map[string]string{"pak.json": test.config})
+
+		_, err := ReadConfig(filepath.Join(root, "pak.json"))
+
+		if test.err == "" {
+			assert.Nil(t, err, test.config)
+		} else if assert.NotNil(t, err, test.config) {
+			assert.Contains(t, err.Error(), test.err, test.config)
+		}
+
+		os.RemoveAll(root)
+	}
+
+	config, err := ReadConfig(DefaultConfig)
+	assert.Nil(t, err)
+	assert.Nil(t, config)
+}
+
+func TestConfigApply(t *testing.T) {
+	root := site(t, map[string]string{
+		"pak.json": `{"entries":["src/index.html"],"root":"src","out":"public","vendor":"lib","allow":["a.com","*.b.com"],"optimize":0}`,
+	})
+
+	defer os.RemoveAll(root)
+
+	config, err := ReadConfig(filepath.Join(root, "pak.json"))
+	assert.Nil(t, err)
+
+	var options Options
+
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+
+	options.Define(flags)
+
+	flags.StringVar(&options.Out, "o", "dist", "")
+
+	assert.Nil(t, flags.Parse([]string{"-o", "elsewhere", "-O", "1"}))
+
+	entries := config.Apply(&options, flags, nil)
+
+	assert.Equal(t, []string{filepath.Join(root, "src", "index.html")}, entries)
+	assert.Equal(t, filepath.Join(root, "src"), options.Root)
+	assert.Equal(t, "elsewhere", options.Out)
+	assert.Equal(t, "lib", options.Vendor)
+	assert.Equal(t, "a.com,*.b.com", options.Allow)
+	assert.Equal(t, 1, options.Optimize)
+
+	assert.Equal(t, []string{"other.html"}, config.Apply(&options, flags, []string{"other.html"}))
+}
+
+func TestMediaTypeOptions(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><style>p{}</style></head><body></body></html>`,
+		"css/app.css":  `@import "base.css";body{background:url(bg.png)}`,
+		"css/base.css": `a{color:red}`,
+		"css/bg.png":   `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	zero, yes := 0, true
+
+	options := Options{
+		Root:     root,
+		Jobs:     1,
+		Optimize: 1,
+		MediaTypes: map[string]*MediaTypeOptions{
+			"text/css":  {Optimize: &zero, Hash: &yes},
+			"image/png": {},
+		},
+	}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+	assert.Nil(t, err)
+
+	var paths []string
+
+	for _, asset := range result.Graph.Assets() {
+		paths = append(paths, asset.URL().Path)
+	}
+
+	assert.Len(t, paths, 4)
+	assert.Contains(t, paths, "/index.html")
+	assert.Contains(t, paths, "/css/bg.png")
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".css") {
+			assert.Regexp(t, `^/css/(app|base)\.[0-9a-f]{8}\.css$`, path)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/css"
+	cssast "github.com/kasperisager/pak/pkg/asset/css/ast"
+	"github.com/kasperisager/pak/pkg/asset/css/token"
+	"github.com/kasperisager/pak/pkg/asset/html"
+	"github.com/kasperisager/pak/pkg/asset/html/ast"
+	"github.com/kasperisager/pak/pkg/asset/js"
+)
+
+// The directives of generated policies, in the order they are written. The
+// first three are always written while the rest are only written when they
+// allow more than the page's own origin.
+var directives = []string{
+	"script-src",
+	"style-src",
+	"connect-src",
+	"img-src",
+	"font-src",
+	"manifest-src",
+}
+
+// computePolicies computes a Content Security Policy for every page of a graph.
+// The policy of a page allows its inline scripts and styles by their hashes
+// and the origins of the remote files that the page loads, directly or not.
+func computePolicies(graph *asset.Graph) map[asset.Asset]string {
+	policies := make(map[asset.Asset]string)
+
+	for _, page := range graph.ByMediaType(html.MediaType) {
+		document, ok := page.(*html.Asset)
+
+		if !ok {
+			continue
+		}
+
+		sources := make(map[string]map[string]bool)
+
+		for _, directive := range directives {
+			sources[directive] = map[string]bool{"'self'": true}
+		}
+
+		it := document.Document.Root.Walk()
+
+		for element, ok := it.Next(); ok; element, ok = it.Next() {
+			switch {
+			case element.Name == "style":
+				sources["style-src"][source(document.Text(element))] = true
+
+			case element.Name == "script" && element.Attribute("src") == nil && executable(element):
+				sources["script-src"][source(document.Text(element))] = true
+			}
+		}
+
+		origins(graph, page, sources, make(map[asset.Asset]bool))
+
+		var policy []string
+
+		policy = append(policy, "default-src 'self'")
+
+		for i, directive := range directives {
+			if i >= 3 && len(sources[directive]) == 1 {
+				continue
+			}
+
+			values := make([]string, 0, len(sources[directive]))
+
+			for value := range sources[directive] {
+				if value != "'self'" {
+					values = append(values, value)
+				}
+			}
+
+			sort.Strings(values)
+
+			policy = append(policy, strings.Join(append([]string{directive, "'self'"}, values...), " "))
+		}
+
+		policies[page] = strings.Join(policy, "; ")
+	}
+
+	return policies
+}
+
+func origins(
+	graph *asset.Graph,
+	from asset.Asset,
+	sources map[string]map[string]bool,
+	visited map[asset.Asset]bool,
+) {
+	if visited[from] {
+		return
+	}
+
+	visited[from] = true
+
+	inlined(from, sources)
+
+	edges, _ := graph.Outgoing(from)
+
+	for _, edge := range edges {
+		relation, related := edge.Relation, edge.Asset
+
+		if reference, ok := relation.(asset.Reference); ok {
+			target := from.URL().ResolveReference(reference.URL())
+
+			if target.IsAbs() {
+				origin := target.Scheme + "://" + target.Host
+				sources[directive(related.MediaType())][origin] = true
+			}
+		}
+
+		origins(graph, related, sources, visited)
+	}
+}
+
+// inlined allows the data URLs of an asset, such as those of the images that
+// were inlined into it, by the directives of their media types.
+func inlined(from asset.Asset, sources map[string]map[string]bool) {
+	switch from := from.(type) {
+	case *html.Asset:
+		it := from.Document.Root.Walk()
+
+		for element, ok := it.Next(); ok; element, ok = it.Next() {
+			// Inline styles are no longer assets of their own once merged into
+			// the page, so their bodies are parsed again.
+			if element.Name == "style" {
+				if style, err := css.From(from.URL(), []byte(from.Text(element))); err == nil {
+					inlinedRules(style.StyleSheet.Rules, sources)
+				}
+			}
+
+			for _, attribute := range element.Attributes {
+				switch {
+				case attribute.Name == "src", attribute.Name == "poster", attribute.Name == "href" && element.Name == "link":
+					dataSource(attribute.Value, sources)
+				}
+			}
+		}
+
+	case *css.Asset:
+		inlinedRules(from.StyleSheet.Rules, sources)
+	}
+}
+
+func inlinedRules(rules []cssast.Rule, sources map[string]map[string]bool) {
+	for _, rule := range rules {
+		switch rule := rule.(type) {
+		case *cssast.StyleRule:
+			inlinedDeclarations(rule.Declarations, sources)
+
+		case *cssast.FontFaceRule:
+			inlinedDeclarations(rule.Declarations, sources)
+
+		case *cssast.KeyframesRule:
+			for _, block := range rule.Blocks {
+				inlinedDeclarations(block.Declarations, sources)
+			}
+
+		case *cssast.MediaRule:
+			inlinedRules(rule.StyleSheet.Rules, sources)
+
+		case *cssast.SupportsRule:
+			inlinedRules(rule.StyleSheet.Rules, sources)
+		}
+	}
+}
+
+func inlinedDeclarations(declarations []*cssast.Declaration, sources map[string]map[string]bool) {
+	for _, declaration := range declarations {
+		for i, t := range declaration.Value {
+			switch t := t.(type) {
+			case token.Url:
+				dataSource(t.Value, sources)
+
+			case token.String:
+				if i > 0 {
+					if previous, ok := declaration.Value[i-1].(token.Function); ok && strings.EqualFold(previous.Value, "url") {
+						dataSource(t.Value, sources)
+					}
+				}
+			}
+		}
+	}
+}
+
+// dataSource allows a URL by the directive of its media type if it is a data URL.
+func dataSource(value string, sources map[string]map[string]bool) {
+	if !strings.HasPrefix(value, "data:") {
+		return
+	}
+
+	mediaType := strings.TrimPrefix(value, "data:")
+
+	if i := strings.IndexAny(mediaType, ";,"); i != -1 {
+		mediaType = mediaType[:i]
+	}
+
+	sources[directive(mediaType)]["data:"] = true
+}
+
+func directive(mediaType string) string {
+	switch {
+	case mediaType == js.MediaType:
+		return "script-src"
+
+	case mediaType == css.MediaType:
+		return "style-src"
+
+	case strings.HasPrefix(mediaType, "image/"):
+		return "img-src"
+
+	case strings.HasPrefix(mediaType, "font/"):
+		return "font-src"
+
+	case strings.HasSuffix(mediaType, "manifest+json"):
+		return "manifest-src"
+
+	default:
+		return "connect-src"
+	}
+}
+
+// executable reports whether an inline script is run by the browser, and so
+// subject to the policy, rather than being a block of data.
+func executable(element *ast.Element) bool {
+	typ := element.Attribute("type")
+
+	if typ == nil {
+		return true
+	}
+
+	switch typ.Value {
+	case "", "module", "importmap", "text/javascript", "application/javascript":
+		return true
+	}
+
+	return false
+}
+
+// source returns the hash source expression that allows an inline body.
+func source(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// embedPolicy adds a policy to a page as the first element of its head so
+// that it applies to everything that follows it.
+func embedPolicy(page asset.Asset, policy string) {
+	document, ok := page.(*html.Asset)
+
+	if !ok {
+		return
+	}
+
+	head, ok := document.Document.Root.Find(ast.ByName("head")).Next()
+
+	if !ok {
+		return
+	}
+
+	meta := &ast.Element{
+		Name: "meta",
+		Attributes: []*ast.Attribute{
+			{Name: "http-equiv", Value: "Content-Security-Policy"},
+			{Name: "content", Value: policy},
+		},
+	}
+
+	head.Children = append([]ast.Node{meta}, head.Children...)
+}
+
+// writeHeaders writes the policies of pages to a file of headers in the
+// format understood by hosts such as Netlify and Cloudflare Pages.
+func writeHeaders(policies map[asset.Asset]string, out string) error {
+	var pages []string
+
+	headers := make(map[string]string)
+
+	for page, policy := range policies {
+		location := page.URL()
+
+		if location.IsAbs() {
+			continue
+		}
+
+		paths := []string{location.Path}
+
+		if path.Base(location.Path) == "index.html" {
+			paths = append(paths, strings.TrimSuffix(location.Path, "index.html"))
+		}
+
+		for _, path := range paths {
+			pages = append(pages, path)
+			headers[path] = policy
+		}
+	}
+
+	sort.Strings(pages)
+
+	var b strings.Builder
+
+	for _, page := range pages {
+		fmt.Fprintf(&b, "%s\n  Content-Security-Policy: %s\n", page, headers[page])
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(out, "_headers"), []byte(b.String()), 0644)
+}
@@ -0,0 +1,151 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Write([]byte(`let a = 1;`))
+	}))
+
+	defer server.Close()
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<style>a{color:red}</style>` +
+			`<script type="module" src="` + server.URL + `/app.js"></script>` +
+			`<script type="application/ld+json">{}</script>` +
+			`<script type="importmap">{"imports":{"a":"/a%20b.js"}}</script>` +
+			`</head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	hash := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	}
+
+	// The import map is rewritten during the build, so its final body is
+	// taken from the output.
+	policy := func(page string) string {
+		body := strings.SplitN(strings.SplitN(page, `<script type="importmap">`, 2)[1], `</script>`, 2)[0]
+
+		return "default-src 'self'; " +
+			"script-src 'self' " + hash(body) + " " + server.URL + "; " +
+			"style-src 'self' " + hash(`a{color:red}`) + "; " +
+			"connect-src 'self'"
+	}
+
+	options := Options{Root: root, Jobs: 1, CSP: "meta"}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+	if assert.Nil(t, err) {
+		for page, actual := range result.Policies {
+			assert.Equal(t, "/index.html", page.URL().Path)
+			assert.Equal(t, policy(string(page.Data())), actual)
+			assert.Contains(t, string(page.Data()), `<script type="application/ld+json">{}</script>`)
+			assert.True(t, strings.HasPrefix(
+				string(page.Data()),
+				`<!doctype html><html><head><meta http-equiv="Content-Security-Policy" content="`,
+			))
+		}
+
+		assert.Len(t, result.Policies, 1)
+	}
+
+	options.CSP = "headers"
+	options.Out = filepath.Join(root, "dist")
+
+	result, err = Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+	if assert.Nil(t, err) && assert.Nil(t, Write(result, options)) {
+		page, err := ioutil.ReadFile(filepath.Join(options.Out, "index.html"))
+
+		if assert.Nil(t, err) {
+			assert.NotContains(t, string(page), "Content-Security-Policy")
+
+			data, err := ioutil.ReadFile(filepath.Join(options.Out, "_headers"))
+
+			if assert.Nil(t, err) {
+				assert.Equal(t,
+					"/\n  Content-Security-Policy: "+policy(string(page))+"\n"+
+						"/index.html\n  Content-Security-Policy: "+policy(string(page))+"\n",
+					string(data),
+				)
+			}
+		}
+	}
+
+	options.CSP = "bogus"
+
+	_, err = Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+	assert.NotNil(t, err)
+}
+
+func TestCSPUnknown(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	sources := make(Sources)
+
+	options := Options{Root: root, Jobs: 1, CSP: "header"}
+
+	_, err := Compile([]*url.URL{{Path: "/index.html"}}, options, sources)
+
+	// The mode is checked before anything is read.
+	assert.EqualError(t, err, `unknown csp "header", expected meta or headers`)
+	assert.Empty(t, sources)
+}
+
+func TestCSPInline(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<link rel="icon" href="icon.png">` +
+			`</head><body></body></html>`,
+		"about.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="app.css">` +
+			`</head><body></body></html>`,
+		"app.css":  `a{background:url(dot.png)}`,
+		"icon.png": `icon`,
+		"dot.png":  `dot`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, CSP: "meta", Inline: 1024}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	policies := make(map[string]string)
+
+	for page, policy := range result.Policies {
+		policies[page.URL().Path] = policy
+	}
+
+	// Images inlined into pages and into the styles of pages are loaded from
+	// data URLs.
+	assert.Equal(t, "default-src 'self'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:", policies["/index.html"])
+	assert.Contains(t, policies["/about.html"], "; img-src 'self' data:")
+}
@@ -0,0 +1,93 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/diagnostic"
+)
+
+// Errors is a list of errors that occurred during a build, sorted by the file
+// and position that they occurred at.
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (errs Errors) Errors() []error {
+	return errs
+}
+
+// errorList gathers errors until it reaches its limit, after which it is
+// full and the build should stop.
+type errorList struct {
+	errs  Errors
+	limit int
+	full  bool
+}
+
+func (l *errorList) add(url *url.URL, err error) {
+	if l.full {
+		return
+	}
+
+	var d *diagnostic.Diagnostic
+
+	if !errors.As(err, &d) {
+		d = &diagnostic.Diagnostic{URL: url, Message: err.Error()}
+	}
+
+	l.errs = append(l.errs, d)
+
+	if l.limit > 0 && len(l.errs) >= l.limit {
+		l.full = true
+	}
+}
+
+func (l *errorList) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+
+	errs := l.errs
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := position(errs[i]), position(errs[j])
+
+		if a.URL.String() != b.URL.String() {
+			return a.URL.String() < b.URL.String()
+		}
+
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	if l.full {
+		errs = append(errs, fmt.Errorf("too many errors, stopped after %d", len(errs)))
+	}
+
+	return errs
+}
+
+func position(err error) *diagnostic.Diagnostic {
+	var d *diagnostic.Diagnostic
+
+	if errors.As(err, &d) {
+		return d
+	}
+
+	return &diagnostic.Diagnostic{URL: &url.URL{}}
+}
@@ -0,0 +1,88 @@
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kasperisager/pak/pkg/diagnostic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="b.css"><link rel="stylesheet" href="missing.css"><link rel="stylesheet" href="a.css"></head><body></body></html>`,
+		"about.html": `<!doctype html><html><head><link rel="stylesheet" href="a.css"></head><body></body></html>`,
+		"a.css":      "a{}\nb }",
+		"b.css":      "x }",
+	})
+
+	defer os.RemoveAll(root)
+
+	urls := []*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}
+
+	var tests = []struct {
+		limit     int
+		positions []string
+		truncated bool
+	}{
+		{0, []string{"/a.css:2:3", "/b.css:1:3", "/missing.css"}, false},
+		{2, []string{"/b.css:1:3", "/missing.css"}, true},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(urls, Options{Root: root, Jobs: 1, MaxErrors: test.limit}, make(Sources))
+
+		errs, ok := err.(Errors)
+		assert.True(t, ok)
+
+		var positions []string
+
+		for _, err := range errs {
+			if d, ok := err.(*diagnostic.Diagnostic); ok {
+				positions = append(positions, d.Position())
+			}
+		}
+
+		assert.Equal(t, test.positions, positions)
+
+		if test.truncated {
+			assert.Len(t, errs, len(test.positions)+1)
+		} else {
+			assert.Len(t, errs, len(test.positions))
+		}
+	}
+}
+
+func TestErrorsStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`@import "more.css";`))
+	}))
+
+	defer server.Close()
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="missing.css"><link rel="stylesheet" href="` + server.URL + `/slow.css"></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	sources := make(Sources)
+
+	_, err := Compile([]*url.URL{{Path: "/index.html"}}, Options{Root: root, Jobs: 2, MaxErrors: 1}, sources)
+	assert.Error(t, err)
+
+	// Files still being loaded when the build stopped must not be added to
+	// the sources after it returns.
+	files := len(sources)
+
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, files, len(sources))
+}
@@ -0,0 +1,158 @@
+package build
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kasperisager/pak/pkg/diagnostic"
+)
+
+const (
+	// StartEvent is emitted once, before anything is read.
+	StartEvent = "start"
+
+	// ReadEvent is emitted for every file that is read, whether from disk, the
+	// network or the lockfile.
+	ReadEvent = "read"
+
+	// WriteEvent is emitted for every file that is written to the output.
+	WriteEvent = "write"
+
+	// DiagnosticEvent is emitted for every error that the build ran into.
+	DiagnosticEvent = "diagnostic"
+
+	// SummaryEvent is emitted once, as the last event of a build.
+	SummaryEvent = "summary"
+)
+
+// An Event is something that happened during a build. When printed as JSON,
+// every event is a single line and has a type that determines which of the
+// other fields are set:
+//
+//	start:      version, entries
+//	read:       url, mediaType, size
+//	write:      url, mediaType, size, file
+//	diagnostic: url, file, line, column, severity, message
+//	summary:    status, errors, files, duration
+//
+// The url of an event is the URL of an asset, which is relative to the root
+// of the build for local files, and the file of an event is a path on disk
+// with forward slashes, relative to the working directory unless the root or
+// the output is given as an absolute path. A diagnostic has a file only if it concerns a local file.
+// Lines and columns are 1-based, with a zero line meaning that a diagnostic
+// concerns a file as a whole. Sizes are in bytes and durations are in
+// milliseconds. Fields are only ever added to this schema, never changed.
+type Event struct {
+	Type      string   `json:"type"`
+	Version   string   `json:"version,omitempty"`
+	Entries   []string `json:"entries,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	MediaType string   `json:"mediaType,omitempty"`
+	Size      *int     `json:"size,omitempty"`
+	File      string   `json:"file,omitempty"`
+	Line      *int     `json:"line,omitempty"`
+	Column    *int     `json:"column,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Status    *int     `json:"status,omitempty"`
+	Errors    *int     `json:"errors,omitempty"`
+	Files     *int     `json:"files,omitempty"`
+	Duration  *int64   `json:"duration,omitempty"`
+}
+
+// A Reporter receives the events of a build. It may be called concurrently.
+type Reporter func(Event)
+
+func (report Reporter) read(source *Source) {
+	if report != nil {
+		report(Event{
+			Type:      ReadEvent,
+			URL:       source.URL.String(),
+			MediaType: source.MediaType,
+			Size:      intPtr(len(source.Data)),
+		})
+	}
+}
+
+// NewJSONReporter returns a reporter that writes events to w as lines of
+// JSON.
+func NewJSONReporter(w io.Writer) Reporter {
+	var lock sync.Mutex
+
+	encoder := json.NewEncoder(w)
+
+	return func(event Event) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		encoder.Encode(event)
+	}
+}
+
+// Diagnostics converts the error of a build to diagnostic events, locating
+// local files in the root directory of the build.
+func Diagnostics(err error, root string) []Event {
+	errs := flatten(err)
+
+	events := make([]Event, len(errs))
+
+	for i, err := range errs {
+		event := Event{
+			Type:     DiagnosticEvent,
+			Severity: "error",
+			Message:  err.Error(),
+		}
+
+		if d, ok := err.(*diagnostic.Diagnostic); ok {
+			event.URL = d.URL.String()
+
+			if !d.URL.IsAbs() {
+				event.File = filepath.ToSlash(filename(d.URL, root))
+			}
+
+			event.Line = intPtr(d.Line)
+			event.Column = intPtr(d.Column)
+			event.Message = d.Message
+		}
+
+		events[i] = event
+	}
+
+	return events
+}
+
+// Summary returns the final event of a build that started at the given time
+// and wrote the given number of files.
+func Summary(err error, files int, start time.Time) Event {
+	status, errs := 0, 0
+
+	if err != nil {
+		status, errs = 1, len(flatten(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+
+	return Event{
+		Type:     SummaryEvent,
+		Status:   intPtr(status),
+		Errors:   intPtr(errs),
+		Files:    intPtr(files),
+		Duration: &duration,
+	}
+}
+
+// flatten returns the errors that the error of a build consists of.
+func flatten(err error) Errors {
+	if errs, ok := err.(Errors); ok {
+		return errs
+	}
+
+	return Errors{err}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,129 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="app.css"><link rel="stylesheet" href="broken.css"></head><body></body></html>`,
+		"app.css":    `a{color:red}`,
+		"broken.css": "a{}\nb }",
+	})
+
+	defer os.RemoveAll(root)
+
+	var b bytes.Buffer
+
+	options := Options{Root: root, Out: filepath.Join(root, "dist"), Jobs: 1, Report: NewJSONReporter(&b)}
+
+	_, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+	assert.NotNil(t, err)
+
+	for _, event := range Diagnostics(err, root) {
+		options.Report(event)
+	}
+
+	options.Report(Summary(err, 0, time.Now()))
+
+	var events []Event
+
+	decoder := json.NewDecoder(&b)
+
+	for decoder.More() {
+		var event Event
+		assert.Nil(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+
+	reads := make(map[string]int)
+
+	for _, event := range events {
+		if event.Type == ReadEvent {
+			reads[event.URL] = *event.Size
+		}
+	}
+
+	assert.Equal(t, map[string]int{"/index.html": 133, "/app.css": 12, "/broken.css": 7}, reads)
+
+	diagnostic := events[len(events)-2]
+	assert.Equal(t, DiagnosticEvent, diagnostic.Type)
+	assert.Equal(t, "/broken.css", diagnostic.URL)
+	assert.Equal(t, filepath.ToSlash(filepath.Join(root, "broken.css")), diagnostic.File)
+	assert.Equal(t, 2, *diagnostic.Line)
+	assert.Equal(t, 3, *diagnostic.Column)
+	assert.Equal(t, "error", diagnostic.Severity)
+	assert.Equal(t, "unexpected token, expected selector", diagnostic.Message)
+
+	summary := events[len(events)-1]
+	assert.Equal(t, SummaryEvent, summary.Type)
+	assert.Equal(t, 1, *summary.Status)
+	assert.Equal(t, 1, *summary.Errors)
+}
+
+func TestWriteEvents(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="app.css"></head><body></body></html>`,
+		"app.css":    `a{color:red}`,
+	})
+
+	defer os.RemoveAll(root)
+
+	var events []Event
+
+	options := Options{Root: root, Out: filepath.Join(root, "dist"), Jobs: 1, Report: func(event Event) {
+		if event.Type == WriteEvent {
+			events = append(events, event)
+		}
+	}}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+	assert.Nil(t, err)
+	assert.Nil(t, Write(result, options))
+
+	files := make(map[string]string)
+
+	for _, event := range events {
+		files[event.URL] = event.File
+		assert.NotZero(t, *event.Size)
+	}
+
+	assert.Equal(t, map[string]string{
+		"/index.html": filepath.ToSlash(filepath.Join(root, "dist", "index.html")),
+		"/app.css":    filepath.ToSlash(filepath.Join(root, "dist", "app.css")),
+	}, files)
+}
+
+func TestStartEvent(t *testing.T) {
+	root := site(t, map[string]string{
+		"pak.json":   `{"entries": ["index.html"]}`,
+		"index.html": `<!doctype html><html><head></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	var events []Event
+
+	options := Options{Root: root, Out: filepath.Join(root, "dist"), Jobs: 1, Report: func(event Event) {
+		events = append(events, event)
+	}}
+
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+
+	assert.Nil(t, run(nil, &options, flags, filepath.Join(root, "pak.json")))
+
+	// The entries of the configuration are reported as those of the build.
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, StartEvent, events[0].Type)
+		assert.Equal(t, []string{filepath.Join(root, "index.html")}, events[0].Entries)
+	}
+}
@@ -0,0 +1,58 @@
+package build
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/css"
+	"github.com/kasperisager/pak/pkg/asset/html"
+)
+
+// extract moves the inline styles and scripts of pages into files of their
+// own, named after the page and the position of the element in it, such as
+// index.inline-0.css. The files are placed next to the page so that relative
+// references within them remain valid.
+func extract(graph *asset.Graph) {
+	for _, page := range graph.ByMediaType(html.MediaType) {
+		edges, _ := graph.Outgoing(page)
+
+		name := strings.TrimSuffix(path.Base(page.URL().Path), path.Ext(page.URL().Path))
+
+		// Embeds are related to the page in document order, which gives the
+		// files names that are stable between builds.
+		i := 0
+
+		for _, edge := range edges {
+			embed, ok := edge.Relation.(*html.Embed)
+
+			if !ok {
+				continue
+			}
+
+			related := edge.Asset
+
+			extension := ".js"
+
+			if related.MediaType() == css.MediaType {
+				extension = ".css"
+			}
+
+			filename := fmt.Sprintf("%s.inline-%d%s", name, i, extension)
+
+			reference, ok := embed.Extract(&url.URL{Path: filename})
+
+			if !ok {
+				continue
+			}
+
+			i++
+
+			graph.Unrelate(page, related, embed)
+			graph.Relate(page, related, reference)
+			graph.Rewrite(related, page.URL().ResolveReference(&url.URL{Path: filename}))
+		}
+	}
+}
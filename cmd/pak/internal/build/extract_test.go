@@ -0,0 +1,61 @@
+package build
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	root := site(t, map[string]string{
+		"pages/index.html": `<!doctype html><html><head>` +
+			`<style media="print">body{background:url(bg.png)}</style>` +
+			`<script type="importmap">{"imports":{}}</script>` +
+			`<script type="application/ld+json">{}</script>` +
+			`</head><body><script type="module"></script></body></html>`,
+		"pages/bg.png": `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	for _, extract := range []bool{false, true} {
+		options := Options{Root: root, Out: filepath.Join(root, "dist"), Jobs: 1, Extract: extract}
+
+		result, err := Compile([]*url.URL{{Path: "/pages/index.html"}}, options, make(Sources))
+
+		if !assert.Nil(t, err) || !assert.Nil(t, Write(result, options)) {
+			continue
+		}
+
+		page, err := ioutil.ReadFile(filepath.Join(options.Out, "pages", "index.html"))
+
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		assert.Contains(t, string(page), `<script type="importmap">`)
+		assert.Contains(t, string(page), `<script type="application/ld+json">{}</script>`)
+
+		styles, err := ioutil.ReadFile(filepath.Join(options.Out, "pages", "index.inline-0.css"))
+
+		if !extract {
+			assert.Contains(t, string(page), `<style media="print">body{background:url(bg.png)}</style>`)
+			assert.True(t, os.IsNotExist(err))
+			continue
+		}
+
+		assert.Contains(t, string(page), `<link rel="stylesheet" media="print" href="index.inline-0.css">`)
+		assert.Contains(t, string(page), `<script type="module" src="index.inline-1.js"></script>`)
+
+		if assert.Nil(t, err) {
+			assert.Equal(t, `body{background:url(bg.png)}`, string(styles))
+		}
+
+		_, err = os.Stat(filepath.Join(options.Out, "pages", "index.inline-1.js"))
+		assert.Nil(t, err)
+	}
+}
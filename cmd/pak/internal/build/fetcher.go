@@ -0,0 +1,202 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
+	"github.com/kasperisager/pak/pkg/asset"
+)
+
+type (
+	fetcher struct {
+		client  *http.Client
+		retries int
+		allow   []string
+		cache   *cache.Cache
+	}
+
+	// A response is what the fetcher keeps in the build cache in order to make
+	// conditional requests for external files it has already fetched.
+	response struct {
+		ETag         string `json:"etag"`
+		LastModified string `json:"lastModified"`
+		ContentType  string `json:"contentType"`
+		Data         []byte `json:"data"`
+	}
+
+	statusError struct {
+		url    *url.URL
+		status string
+		code   int
+	}
+
+	// A policyError is returned when fetching an external file is refused by
+	// the fetcher itself and so retrying it would be pointless.
+	policyError struct {
+		message string
+	}
+)
+
+func newFetcher(options Options, cache *cache.Cache) *fetcher {
+	fetcher := &fetcher{
+		retries: options.Retries,
+		cache:   cache,
+	}
+
+	for _, host := range strings.Split(options.Allow, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			fetcher.allow = append(fetcher.allow, strings.ToLower(host))
+		}
+	}
+
+	fetcher.client = &http.Client{
+		Timeout: options.Timeout,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) > options.Redirects {
+				return policyError{fmt.Sprintf("%s: stopped after %d redirects", via[0].URL, options.Redirects)}
+			}
+
+			return fetcher.check(request.URL)
+		},
+	}
+
+	return fetcher
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("%s: %s", err.url, err.status)
+}
+
+func (err policyError) Error() string {
+	return err.message
+}
+
+func (f *fetcher) fetch(url *url.URL, flags asset.Flags) (mediaType string, data []byte, err error) {
+	if err := f.check(url); err != nil {
+		return "", nil, err
+	}
+
+	var response *response
+
+	for attempt := 0; ; attempt++ {
+		response, err = f.request(url)
+
+		if err == nil || attempt >= f.retries || !retryable(err) {
+			break
+		}
+
+		time.Sleep(time.Duration(100<<uint(attempt)) * time.Millisecond)
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return detect(url, response.Data, flags, response.ContentType), response.Data, nil
+}
+
+func (f *fetcher) request(url *url.URL) (*response, error) {
+	request, err := http.NewRequest("GET", url.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := cache.Key([]byte("http"), []byte(url.String()))
+
+	var cached *response
+
+	if f.cache != nil {
+		if data, ok := f.cache.Get(key); ok {
+			if err := json.Unmarshal(data, &cached); err != nil {
+				cached = nil
+			}
+		}
+	}
+
+	if cached != nil {
+		if cached.ETag != "" {
+			request.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		if cached.LastModified != "" {
+			request.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	result, err := f.client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer result.Body.Close()
+
+	if result.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+
+	if result.StatusCode < 200 || result.StatusCode > 299 {
+		return nil, statusError{url, result.Status, result.StatusCode}
+	}
+
+	data, err := ioutil.ReadAll(result.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := &response{
+		ETag:         result.Header.Get("ETag"),
+		LastModified: result.Header.Get("Last-Modified"),
+		ContentType:  result.Header.Get("Content-Type"),
+		Data:         data,
+	}
+
+	if f.cache != nil && (fetched.ETag != "" || fetched.LastModified != "") {
+		if encoded, err := json.Marshal(fetched); err == nil {
+			f.cache.Put(key, encoded)
+		}
+	}
+
+	return fetched, nil
+}
+
+func (f *fetcher) check(url *url.URL) error {
+	if len(f.allow) == 0 {
+		return nil
+	}
+
+	host := strings.ToLower(url.Hostname())
+
+	for _, allowed := range f.allow {
+		if host == allowed {
+			return nil
+		}
+
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return nil
+		}
+	}
+
+	return policyError{fmt.Sprintf("%s: host %s is not allowed", url, host)}
+}
+
+func retryable(err error) bool {
+	var status statusError
+
+	if errors.As(err, &status) {
+		return status.code >= 500 || status.code == http.StatusTooManyRequests
+	}
+
+	var policy policyError
+
+	return !errors.As(err, &policy)
+}
@@ -0,0 +1,107 @@
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
+)
+
+func TestFetcher(t *testing.T) {
+	var failures, requests, revalidations int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch r.URL.Path {
+		case "/app.css":
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			w.Write([]byte("a{color:red}"))
+
+		case "/flaky.css":
+			if failures < 2 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte("a{color:red}"))
+
+		case "/etag.css":
+			if r.Header.Get("If-None-Match") == `"foo"` {
+				revalidations++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", `"foo"`)
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte("a{color:red}"))
+
+		case "/redirect":
+			http.Redirect(w, r, "/redirect", http.StatusFound)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer server.Close()
+
+	dir := site(t, nil)
+
+	defer os.RemoveAll(dir)
+
+	fetcher := newFetcher(Options{
+		Timeout:   time.Second,
+		Retries:   2,
+		Redirects: 3,
+	}, cache.Open(dir))
+
+	resource := func(path string) *url.URL {
+		url, _ := url.Parse(server.URL + path)
+		return url
+	}
+
+	mediaType, data, err := fetcher.fetch(resource("/app.css"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "text/css", mediaType)
+	assert.Equal(t, "a{color:red}", string(data))
+
+	_, _, err = fetcher.fetch(resource("/missing.css"), nil)
+	assert.EqualError(t, err, server.URL+"/missing.css: 404 Not Found")
+
+	_, data, err = fetcher.fetch(resource("/flaky.css"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a{color:red}", string(data))
+
+	for i := 0; i < 2; i++ {
+		_, data, err = fetcher.fetch(resource("/etag.css"), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a{color:red}", string(data))
+	}
+
+	assert.Equal(t, 1, revalidations)
+
+	requests = 0
+
+	_, _, err = fetcher.fetch(resource("/redirect"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 4, requests)
+
+	fetcher = newFetcher(Options{Allow: "example.com, *.example.org"}, nil)
+
+	assert.Nil(t, fetcher.check(&url.URL{Scheme: "https", Host: "example.com"}))
+	assert.Nil(t, fetcher.check(&url.URL{Scheme: "https", Host: "cdn.example.org"}))
+	assert.Error(t, fetcher.check(&url.URL{Scheme: "https", Host: "example.net"}))
+
+	_, _, err = fetcher.fetch(resource("/app.css"), nil)
+	assert.Error(t, err)
+}
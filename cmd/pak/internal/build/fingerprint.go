@@ -0,0 +1,86 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+
+	"github.com/kasperisager/pak/pkg/asset/html"
+)
+
+// fingerprint gives assets their final names by adding content hashes to them
+// and, if a public URL is given, pointing references to them at that URL. Both
+// change the content of the assets that reference them and so are done in
+// the same pass.
+func fingerprint(
+	graph *asset.Graph,
+	entries []asset.Asset,
+	hash func(mediaType string) bool,
+	public *url.URL,
+) {
+	keep := make(map[asset.Asset]bool)
+
+	for _, entry := range entries {
+		keep[entry] = true
+	}
+
+	visited := make(map[asset.Asset]bool)
+
+	for _, asset := range graph.Assets() {
+		rename(graph, asset, hash, public, keep, visited)
+	}
+}
+
+func rename(
+	graph *asset.Graph,
+	asset asset.Asset,
+	hash func(mediaType string) bool,
+	public *url.URL,
+	keep map[asset.Asset]bool,
+	visited map[asset.Asset]bool,
+) {
+	if visited[asset] {
+		return
+	}
+
+	visited[asset] = true
+
+	// The name of an asset depends on the names of the assets it references,
+	// so these must be renamed first.
+	edges, _ := graph.Outgoing(asset)
+
+	for _, edge := range edges {
+		related := edge.Asset
+
+		rename(graph, related, hash, public, keep, visited)
+	}
+
+	url := asset.URL()
+
+	if url.IsAbs() || asset.MediaType() == html.MediaType {
+		return
+	}
+
+	if !keep[asset] && hash(asset.MediaType()) {
+		graph.Rewrite(asset, hashed(url, asset.Data()))
+	}
+
+	if public != nil {
+		graph.Publish(asset, published(public, asset.URL()))
+	}
+}
+
+func hashed(from *url.URL, data []byte) *url.URL {
+	sum := sha256.Sum256(data)
+
+	ext := path.Ext(from.Path)
+
+	to := *from
+	to.Path = strings.TrimSuffix(from.Path, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+
+	return &to
+}
@@ -0,0 +1,55 @@
+package build
+
+import (
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	root := site(t, map[string]string{
+		"blog/index.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="../css/app.css">` +
+			`<style>b{background:url(../img/a.png)}</style>` +
+			`</head><body></body></html>`,
+		"css/app.css":   `a{background:url(../img/icons.svg#foo)}`,
+		"img/a.png":     `png`,
+		"img/icons.svg": `svg`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, Hash: true}
+
+	result, err := Compile([]*url.URL{{Path: "/blog/index.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	data := make(map[string]string)
+
+	for _, asset := range result.Graph.Assets() {
+		data[result.Origins[asset].Path] = string(asset.Data())
+
+		// Only the entry keeps its name.
+		if result.Origins[asset].Path == "/blog/index.html" {
+			assert.Equal(t, "/blog/index.html", asset.URL().Path)
+		} else {
+			assert.NotEqual(t, result.Origins[asset].Path, asset.URL().Path)
+		}
+	}
+
+	name := func(path string) string {
+		return hashed(&url.URL{Path: path}, []byte(data[path])).Path
+	}
+
+	assert.Equal(t, "a{background:url(.."+name("/img/icons.svg")+"#foo)}", data["/css/app.css"])
+
+	assert.Equal(t, `<!doctype html><html><head>`+
+		`<link rel="stylesheet" href="..`+name("/css/app.css")+`">`+
+		`<style>b{background:url(..`+name("/img/a.png")+`)}</style>`+
+		`</head><body></body></html>`, data["/blog/index.html"])
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/css"
+	"github.com/kasperisager/pak/pkg/asset/html"
+)
+
+// inline inlines local stylesheets, scripts and images of at most the given
+// number of bytes into the assets that reference them. Images are inlined as
+// data URIs while stylesheets and scripts referenced from pages are turned
+// into inline styles and scripts. Inlined assets that are no longer
+// referenced are dropped from the graph.
+func inline(graph *asset.Graph, entries []asset.Asset, threshold int) error {
+	keep := make(map[asset.Asset]bool, len(entries))
+
+	for _, entry := range entries {
+		keep[entry] = true
+	}
+
+	// Images are inlined first so that they are part of the stylesheets that
+	// are inlined next.
+	for _, image := range graph.Assets() {
+		if !strings.HasPrefix(image.MediaType(), "image/") || !small(image, threshold) {
+			continue
+		}
+
+		edges, _ := graph.Incoming(image)
+
+		for _, edge := range edges {
+			relation, from := edge.Relation, edge.Asset
+
+			switch reference := relation.(type) {
+			case *html.Reference:
+				if rel := reference.Element.Attribute("rel"); rel == nil || rel.Value != "icon" {
+					continue
+				}
+
+			case *css.Reference:
+
+			default:
+				continue
+			}
+
+			relation.(asset.Reference).Rewrite(&url.URL{
+				Scheme: "data",
+				Opaque: image.MediaType() + ";base64," + base64.StdEncoding.EncodeToString(image.Data()),
+			})
+
+			graph.Unrelate(from, image, relation)
+		}
+
+		drop(graph, image, keep)
+	}
+
+	for _, page := range graph.ByMediaType(html.MediaType) {
+		edges, _ := graph.Outgoing(page)
+
+		for _, edge := range edges {
+			relation, related := edge.Relation, edge.Asset
+
+			reference, ok := relation.(*html.Reference)
+
+			if !ok || !small(related, threshold) {
+				continue
+			}
+
+			embed, ok := reference.Inline()
+
+			if !ok {
+				continue
+			}
+
+			// The asset may be referenced from elsewhere, so a copy of it is
+			// merged into the page in its place.
+			inlined, err := parse(related.URL(), related.Data(), related.MediaType(), reference.Flags())
+
+			if err != nil {
+				return err
+			}
+
+			graph.Add(inlined)
+
+			targets := make(map[string]asset.Asset)
+
+			outgoing, _ := graph.Outgoing(related)
+
+			for _, edge := range outgoing {
+				relation, target := edge.Relation, edge.Asset
+
+				if reference, ok := relation.(asset.Reference); ok {
+					targets[related.URL().ResolveReference(reference.URL()).String()] = target
+				}
+			}
+
+			for _, reference := range inlined.References() {
+				if target, ok := targets[inlined.URL().ResolveReference(reference.URL()).String()]; ok {
+					graph.Relate(inlined, target, reference)
+				}
+			}
+
+			// The references of the copy must be relative to the page before
+			// it is merged into the page.
+			graph.Rewrite(inlined, page.URL())
+
+			graph.Unrelate(page, related, relation)
+			graph.Relate(page, inlined, embed)
+			graph.Merge(page, inlined)
+
+			drop(graph, related, keep)
+		}
+	}
+
+	return nil
+}
+
+func small(asset asset.Asset, threshold int) bool {
+	return !asset.URL().IsAbs() && len(asset.Data()) <= threshold
+}
+
+// drop removes an asset from the graph if nothing references it anymore.
+func drop(graph *asset.Graph, asset asset.Asset, keep map[asset.Asset]bool) {
+	if indegree, _ := graph.Indegree(asset); indegree == 0 && !keep[asset] {
+		graph.Delete(asset)
+	}
+}
@@ -0,0 +1,126 @@
+package build
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInline(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="css/small.css" media="print">` +
+			`<link rel="stylesheet" href="css/large.css">` +
+			`<link rel="icon" href="icon.png">` +
+			`</head><body></body></html>`,
+		"about.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="css/small.css">` +
+			`<link rel="preload" href="icon.png">` +
+			`</head><body></body></html>`,
+		"css/small.css": `a{background:url(img/dot.png)}b{background:url(img/large.png)}`,
+		"css/large.css": `a{color:red;background:url(img/dot.png)}b{color:green}i{color:blue}` +
+			`em{color:yellow}strong{color:orange}`,
+		"css/img/dot.png": `dot`,
+		"css/img/large.png": `a large image that is not inlined as it is way too big` +
+			`a large image that is not inlined as it is way too big`,
+		"icon.png": `icon`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, Hash: true, Inline: 100}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	data := make(map[string]string)
+
+	for _, asset := range result.Graph.Assets() {
+		data[asset.URL().Path] = string(asset.Data())
+	}
+
+	image := regexp.MustCompile(`^/css/img/large\.[0-9a-f]+\.png$`)
+	icon := regexp.MustCompile(`^/icon\.[0-9a-f]+\.png$`)
+
+	large := ""
+
+	for location, contents := range data {
+		switch path.Ext(location) {
+		case ".css":
+			// Only the large stylesheet is kept, with the small image inlined.
+			assert.Regexp(t, `^/css/large\.`, location)
+			assert.Contains(t, contents, `url(data:image/png;base64,ZG90)`)
+
+		case ".png":
+			// The icon is still preloaded by the about page and so kept.
+			assert.Regexp(t, image.String()+"|"+icon.String(), location)
+
+			if image.MatchString(location) {
+				large = location
+			}
+		}
+	}
+
+	if !assert.NotEmpty(t, large) {
+		return
+	}
+
+	// The small stylesheet is inlined into both pages and so dropped, as is
+	// the small image that it references. References in the inlined
+	// stylesheet are rebased onto the pages.
+	assert.True(t, strings.HasPrefix(data["/index.html"], `<!doctype html><html><head>`+
+		`<style media="print">a{background:url(data:image/png;base64,ZG90)}b{background:url(`+large[1:]+`)}</style>`+
+		`<link rel="stylesheet" href="css/large.`,
+	), data["/index.html"])
+
+	assert.Contains(t, data["/index.html"], `<link rel="icon" href="data:image/png;base64,aWNvbg==">`)
+	assert.Contains(t, data["/about.html"], `<style>a{background:url(data:image/png;base64,ZG90)}`)
+}
+
+func TestInlineScripts(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<script src="classic.js"></script>` +
+			`<script src="deferred.js" defer></script>` +
+			`<script type="module" src="module.js"></script>` +
+			`</head><body></body></html>`,
+		"classic.js":  `"classic"`,
+		"deferred.js": `"deferred"`,
+		"module.js":   `"module"`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, Inline: 100}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	data := make(map[string]string)
+
+	for _, asset := range result.Graph.Assets() {
+		data[asset.URL().Path] = string(asset.Data())
+	}
+
+	// Scripts without a type are classic scripts, which are inlined unless
+	// that would change when they run.
+	assert.Equal(t, `<!doctype html><html><head>`+
+		`<script>"classic"</script>`+
+		`<script src="deferred.js" defer></script>`+
+		`<script type="module">"module"</script>`+
+		`</head><body></body></html>`, data["/index.html"])
+
+	assert.Contains(t, data, "/deferred.js")
+	assert.NotContains(t, data, "/classic.js")
+}
@@ -0,0 +1,176 @@
+package build
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/html"
+)
+
+// The hash algorithms of integrity metadata, from weakest to strongest.
+var algorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// sign sets integrity attributes on the script and stylesheet elements of
+// pages, computed from the final contents of the assets they reference. Remote
+// assets that are not written to the output are served as they were fetched,
+// so their fetched contents are used instead. Unless all elements are to be
+// signed, only elements that already have an integrity attribute are updated
+// as their contents may have changed during the build.
+func sign(graph *asset.Graph, sources Sources, all bool) {
+	for _, asset := range graph.Assets() {
+		edges, _ := graph.Outgoing(asset)
+
+		for _, edge := range edges {
+			relation, related := edge.Relation, edge.Asset
+
+			reference, ok := relation.(*html.Reference)
+
+			if !ok || !subresource(reference) {
+				continue
+			}
+
+			element := reference.Element
+
+			if !all && element.Attribute("integrity") == nil {
+				continue
+			}
+
+			data := related.Data()
+
+			if related.URL().IsAbs() {
+				source, ok := sources[key(related.URL())]
+
+				if !ok {
+					continue
+				}
+
+				data = source.Data
+			}
+
+			element.SetAttribute("integrity", integrity(data))
+
+			if element.Attribute("crossorigin") == nil {
+				element.SetAttribute("crossorigin", "anonymous")
+			}
+		}
+	}
+}
+
+// verify checks the integrity attributes of elements that reference remote
+// assets against the contents that were fetched for them.
+func verify(graph *asset.Graph, sources Sources, errs *errorList) {
+	for _, asset := range graph.Assets() {
+		edges, _ := graph.Outgoing(asset)
+
+		for _, edge := range edges {
+			relation, related := edge.Relation, edge.Asset
+
+			reference, ok := relation.(*html.Reference)
+
+			if !ok || !subresource(reference) || !related.URL().IsAbs() {
+				continue
+			}
+
+			attribute := reference.Element.Attribute("integrity")
+
+			if attribute == nil {
+				continue
+			}
+
+			source, ok := sources[key(related.URL())]
+
+			if !ok {
+				continue
+			}
+
+			if err := match(attribute.Value, source.Data); err != nil {
+				errs.add(asset.URL(), fmt.Errorf("%s: %s", related.URL(), err))
+			}
+		}
+	}
+}
+
+func subresource(reference *html.Reference) bool {
+	element := reference.Element
+
+	if element == nil {
+		return false
+	}
+
+	switch element.Name {
+	case "script":
+		return true
+
+	case "link":
+		rel := element.Attribute("rel")
+		return rel != nil && rel.Value == "stylesheet"
+	}
+
+	return false
+}
+
+// match checks data against integrity metadata, which is a list of hashes of
+// which only those using the strongest algorithm in the list are considered.
+//
+// See: https://www.w3.org/TR/SRI/#does-response-match-metadatalist
+func match(metadata string, data []byte) error {
+	strongest := -1
+
+	var digests []string
+
+	for _, item := range strings.Fields(metadata) {
+		// Options following the digest are reserved and ignored.
+		item = strings.SplitN(item, "?", 2)[0]
+
+		parts := strings.SplitN(item, "-", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		for i, algorithm := range algorithms {
+			if algorithm.name != parts[0] {
+				continue
+			}
+
+			if i > strongest {
+				strongest, digests = i, nil
+			}
+
+			if i == strongest {
+				digests = append(digests, parts[1])
+			}
+		}
+	}
+
+	if strongest == -1 {
+		return nil
+	}
+
+	algorithm := algorithms[strongest]
+
+	hash := algorithm.hash()
+	hash.Write(data)
+
+	actual := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	for _, digest := range digests {
+		if digest == actual {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("integrity mismatch, expected %s but got %s-%s", metadata, algorithm.name, actual)
+}
@@ -0,0 +1,106 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	data := []byte("alert(1)")
+
+	sum := sha256.Sum256(data)
+	sha256 := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+	sha384 := integrity(data)
+
+	var tests = []struct {
+		metadata string
+		ok       bool
+	}{
+		{sha384, true},
+		{sha256, true},
+		{sha256 + "?foo", true},
+		{"sha384-bad " + sha384, true},
+		{sha256 + " sha384-bad", false},
+		{"sha384-bad", false},
+		{"md5-whatever", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		err := match(test.metadata, data)
+		assert.Equal(t, test.ok, err == nil, test.metadata)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	script := []byte(`import "./dep.js";`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+
+		if r.URL.Path == "/app.js" {
+			w.Write(script)
+		}
+	}))
+
+	defer server.Close()
+
+	var tests = []struct {
+		attribute string
+		sri       bool
+		err       bool
+	}{
+		{``, true, false},
+		{` integrity="` + integrity(script) + `"`, false, false},
+		{` integrity="sha384-bad"`, false, true},
+	}
+
+	for _, test := range tests {
+		root := site(t, map[string]string{
+			"index.html": `<!doctype html><html><head>` +
+				`<link rel="stylesheet" href="app.css">` +
+				`<script type="module" src="` + server.URL + `/app.js"` + test.attribute + `></script>` +
+				`</head><body></body></html>`,
+			"app.css": `a{color:red}`,
+		})
+
+		options := Options{Root: root, Jobs: 1, Integrity: test.sri}
+
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+		os.RemoveAll(root)
+
+		if test.err {
+			if assert.NotNil(t, err, test.attribute) {
+				assert.Contains(t, err.Error(), "integrity mismatch")
+			}
+
+			continue
+		}
+
+		assert.Nil(t, err, test.attribute)
+
+		for _, asset := range result.Graph.Assets() {
+			if asset.URL().Path != "/index.html" || asset.MediaType() != "text/html" {
+				continue
+			}
+
+			data := string(asset.Data())
+
+			assert.Contains(t, data, `integrity="`+integrity(script)+`" crossorigin="anonymous"`)
+
+			if test.sri {
+				assert.Contains(t, data, `href="app.css" integrity="`+integrity([]byte(`a{color:red}`))+`" crossorigin="anonymous"`)
+			} else {
+				assert.NotContains(t, data, `href="app.css" integrity`)
+			}
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package build
+
+import (
+	"encoding"
+	"errors"
+	"net/url"
+	"runtime"
+	"sync"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
+	"github.com/kasperisager/pak/pkg/asset"
+)
+
+type (
+	loader struct {
+		root     string
+		sources  Sources
+		cache    *cache.Cache
+		fetcher  *fetcher
+		lockfile *lockfile
+		report   Reporter
+		jobs     chan bool
+		lock     sync.Mutex
+		nodes    map[string]*node
+		pending  sync.WaitGroup
+		stopped  bool
+
+		// The nodes kept from an earlier build, if any, and those of them that
+		// relate to assets that have changed since.
+		kept  map[string]*node
+		stale map[*node]bool
+	}
+
+	// A node is an asset that is being, or has been, fetched and parsed by a
+	// loader. The fields of a node must not be read until done is closed.
+	node struct {
+		url        *url.URL
+		done       chan bool
+		asset      asset.Asset
+		err        error
+		references []asset.Reference
+		referenced []*node
+		embeds     []asset.Embed
+		embedded   []*node
+	}
+)
+
+// errStopped is the error of nodes that were not loaded as the loader was
+// stopped before getting to them.
+var errStopped = errors.New("build stopped")
+
+func newLoader(
+	root string,
+	sources Sources,
+	cache *cache.Cache,
+	fetcher *fetcher,
+	lockfile *lockfile,
+	jobs int,
+	report Reporter,
+) *loader {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	return &loader{
+		root:     root,
+		sources:  sources,
+		cache:    cache,
+		fetcher:  fetcher,
+		lockfile: lockfile,
+		report:   report,
+		jobs:     make(chan bool, jobs),
+		nodes:    make(map[string]*node),
+	}
+}
+
+func (l *loader) resolve(url *url.URL, flags asset.Flags) *node {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if node, ok := l.nodes[key(url)]; ok {
+		return node
+	}
+
+	if kept, ok := l.kept[key(url)]; ok {
+		node := kept
+
+		if l.stale[kept] {
+			node = l.relink(kept)
+		}
+
+		l.nodes[key(url)] = node
+
+		return node
+	}
+
+	node := &node{url: url, done: make(chan bool)}
+
+	l.nodes[key(url)] = node
+
+	l.pending.Add(1)
+
+	go l.run(node, func() (asset.Asset, error) {
+		mediaType, data, err := l.load(url, flags)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return l.parse(url, data, mediaType, flags)
+	})
+
+	return node
+}
+
+func (l *loader) embed(url *url.URL, embed asset.Embed) *node {
+	node := &node{url: url, done: make(chan bool)}
+
+	l.pending.Add(1)
+
+	go l.run(node, func() (asset.Asset, error) {
+		return l.parse(url, embed.Data(), embed.MediaType(), embed.Flags())
+	})
+
+	return node
+}
+
+func (l *loader) run(node *node, resolve func() (asset.Asset, error)) {
+	defer l.pending.Done()
+	defer close(node.done)
+
+	l.jobs <- true
+
+	if l.isStopped() {
+		node.err = errStopped
+	} else {
+		node.asset, node.err = resolve()
+	}
+
+	<-l.jobs
+
+	if node.err != nil || l.isStopped() {
+		return
+	}
+
+	node.references = node.asset.References()
+	node.embeds = node.asset.Embeds()
+
+	l.link(node, nil)
+}
+
+// relink returns a copy of a node kept from an earlier build whose asset is
+// related anew, as some of the assets that it relates to have changed since.
+// The asset itself is neither loaded nor parsed again.
+func (l *loader) relink(kept *node) *node {
+	node := &node{
+		url:        kept.url,
+		done:       make(chan bool),
+		asset:      kept.asset,
+		references: kept.references,
+		embeds:     kept.embeds,
+	}
+
+	l.pending.Add(1)
+
+	go func() {
+		defer l.pending.Done()
+		defer close(node.done)
+
+		if l.isStopped() {
+			node.err = errStopped
+		} else {
+			l.link(node, kept)
+		}
+	}()
+
+	return node
+}
+
+// link resolves the assets that the asset of a node relates to. The embeds of
+// a node kept from an earlier build are reused rather than parsed again.
+func (l *loader) link(node *node, kept *node) {
+	url := node.asset.URL()
+
+	for _, reference := range node.references {
+		node.referenced = append(
+			node.referenced,
+			l.resolve(url.ResolveReference(reference.URL()), reference.Flags()),
+		)
+	}
+
+	for i, embed := range node.embeds {
+		switch {
+		case kept == nil:
+			node.embedded = append(node.embedded, l.embed(url, embed))
+
+		case l.stale[kept.embedded[i]]:
+			node.embedded = append(node.embedded, l.relink(kept.embedded[i]))
+
+		default:
+			node.embedded = append(node.embedded, kept.embedded[i])
+		}
+	}
+}
+
+// stop keeps the loader from loading any more assets and waits for those that
+// are being loaded, after which the sources of the loader are safe to read.
+func (l *loader) stop() {
+	l.lock.Lock()
+	l.stopped = true
+	l.lock.Unlock()
+
+	l.pending.Wait()
+}
+
+func (l *loader) isStopped() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.stopped
+}
+
+func (l *loader) parse(
+	url *url.URL,
+	data []byte,
+	mediaType string,
+	flags asset.Flags,
+) (asset.Asset, error) {
+	if l.cache == nil {
+		return parse(url, data, mediaType, flags)
+	}
+
+	key := cache.Key(
+		[]byte(Version),
+		[]byte(runtime.Version()),
+		[]byte(mediaType),
+		[]byte(flags.String()),
+		data,
+	)
+
+	if cached, ok := l.cache.Get(key); ok {
+		if decoded, err := decode(url, cached, mediaType); err == nil {
+			return decoded, nil
+		}
+	}
+
+	parsed, err := parse(url, data, mediaType, flags)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to write to the cache only costs a parse on the next build, so
+	// it is not worth failing this one over.
+	if marshaler, ok := parsed.(encoding.BinaryMarshaler); ok {
+		if encoded, err := marshaler.MarshalBinary(); err == nil {
+			l.cache.Put(key, encoded)
+		}
+	}
+
+	return parsed, nil
+}
+
+func (l *loader) load(url *url.URL, flags asset.Flags) (mediaType string, data []byte, err error) {
+	l.lock.Lock()
+	source, cached := l.sources[key(url)]
+	l.lock.Unlock()
+
+	if cached {
+		mediaType, data = source.MediaType, source.Data
+	} else {
+		mediaType, data, err = l.fetch(url, flags)
+
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if url.IsAbs() && l.lockfile != nil {
+		if err := l.lockfile.verify(url, mediaType, data); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if !cached {
+		source := &Source{url, mediaType, data}
+
+		l.lock.Lock()
+		l.sources[key(url)] = source
+		l.lock.Unlock()
+
+		l.report.read(source)
+	}
+
+	return mediaType, data, nil
+}
+
+func (l *loader) fetch(url *url.URL, flags asset.Flags) (mediaType string, data []byte, err error) {
+	if url.IsAbs() {
+		if l.lockfile != nil && l.lockfile.offline {
+			return l.lockfile.load(url)
+		}
+
+		return l.fetcher.fetch(url, flags)
+	}
+
+	return fetch(url, l.root, flags)
+}
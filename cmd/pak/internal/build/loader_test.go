@@ -0,0 +1,133 @@
+package build
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderDedup(t *testing.T) {
+	var lock sync.Mutex
+
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests[r.URL.String()]++
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`a{color:red}`))
+	}))
+
+	defer server.Close()
+
+	page := `<!doctype html><html><head>` +
+		`<link rel="stylesheet" href="` + server.URL + `/app.css">` +
+		`<link rel="stylesheet" href="` + server.URL + `/app.css#foo">` +
+		`<link rel="stylesheet" href="` + server.URL + `/app.css?v=2">` +
+		`<link rel="stylesheet" href="css/app.css">` +
+		`</head><body></body></html>`
+
+	root := site(t, map[string]string{
+		"index.html":  page,
+		"about.html":  page,
+		"css/app.css": `@import "` + server.URL + `/app.css";`,
+	})
+
+	defer os.RemoveAll(root)
+
+	sources := make(Sources)
+
+	_, err := Compile([]*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}, Options{Root: root, Jobs: 4}, sources)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]int{"/app.css": 1, "/app.css?v=2": 1}, requests)
+	assert.Len(t, sources, 5)
+}
+
+func TestLoaderJobs(t *testing.T) {
+	var lock sync.Mutex
+
+	running, most := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		running++
+
+		if running > most {
+			most = running
+		}
+
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprintf(w, `.a%s{color:red}`, r.URL.Path[1:2])
+	}))
+
+	defer server.Close()
+
+	page := `<!doctype html><html><head>`
+
+	for i := 0; i < 8; i++ {
+		page += fmt.Sprintf(`<link rel="stylesheet" href="%s/%d.css">`, server.URL, i)
+	}
+
+	root := site(t, map[string]string{
+		"index.html": page + `</head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	var order []string
+
+	for _, jobs := range []int{1, 2, 8} {
+		lock.Lock()
+		most = 0
+		lock.Unlock()
+
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, Options{Root: root, Jobs: jobs}, make(Sources))
+
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.True(t, most <= jobs, "%d jobs, %d requests at once", jobs, most)
+
+		if jobs > 1 {
+			assert.True(t, most > 1, "%d jobs, %d requests at once", jobs, most)
+		}
+
+		var urls []string
+
+		for _, asset := range result.Graph.Assets() {
+			urls = append(urls, asset.URL().String()+" "+string(asset.Data()))
+		}
+
+		// The order of the graph follows the page rather than the order in
+		// which files happen to finish loading.
+		if order == nil {
+			order = urls
+		} else {
+			assert.Equal(t, order, urls)
+		}
+	}
+
+	assert.Len(t, order, 9)
+}
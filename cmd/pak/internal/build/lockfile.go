@@ -0,0 +1,186 @@
+package build
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
+)
+
+type (
+	lockfile struct {
+		path    string
+		store   *cache.Cache
+		update  bool
+		offline bool
+		lock    sync.Mutex
+		locked  map[string]*Remote
+		fetched map[string]*Remote
+	}
+
+	Lock struct {
+		Remotes []*Remote `json:"remotes"`
+	}
+
+	Remote struct {
+		URL       string `json:"url"`
+		MediaType string `json:"mediaType"`
+		Integrity string `json:"integrity"`
+	}
+)
+
+func openLockfile(path string, store *cache.Cache, update bool, offline bool) (*lockfile, error) {
+	lockfile := &lockfile{
+		path:    path,
+		store:   store,
+		update:  update,
+		offline: offline,
+		locked:  make(map[string]*Remote),
+		fetched: make(map[string]*Remote),
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lockfile, nil
+		}
+
+		return nil, err
+	}
+
+	var lock Lock
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	for _, remote := range lock.Remotes {
+		lockfile.locked[remote.URL] = remote
+	}
+
+	return lockfile, nil
+}
+
+func (l *lockfile) load(url *url.URL) (mediaType string, data []byte, err error) {
+	l.lock.Lock()
+	remote, ok := l.locked[key(url)]
+	l.lock.Unlock()
+
+	if !ok {
+		return "", nil, fmt.Errorf("%s: not in %s, which is required when offline", url, l.path)
+	}
+
+	if l.store != nil {
+		data, ok = l.store.Get(contentKey(remote.Integrity))
+	}
+
+	if !ok {
+		return "", nil, fmt.Errorf("%s: not in the build cache, which is required when offline", url)
+	}
+
+	return remote.MediaType, data, nil
+}
+
+func (l *lockfile) verify(url *url.URL, mediaType string, data []byte) error {
+	remote := &Remote{
+		URL:       key(url),
+		MediaType: mediaType,
+		Integrity: integrity(data),
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if locked, ok := l.locked[remote.URL]; ok && !l.update {
+		if locked.Integrity != remote.Integrity {
+			return fmt.Errorf(
+				"%s: content does not match %s, expected %s but got %s",
+				url,
+				l.path,
+				locked.Integrity,
+				remote.Integrity,
+			)
+		}
+	}
+
+	l.fetched[remote.URL] = remote
+
+	if l.store != nil {
+		if key := contentKey(remote.Integrity); !l.store.Has(key) {
+			l.store.Put(key, data)
+		}
+	}
+
+	return nil
+}
+
+// save writes the files fetched by the build to the lockfile. Files that are
+// locked but were not fetched are kept, as a build of only some entries might
+// not need them.
+func (l *lockfile) save() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	remotes := make(map[string]*Remote, len(l.locked)+len(l.fetched))
+
+	for url, remote := range l.locked {
+		remotes[url] = remote
+	}
+
+	for url, remote := range l.fetched {
+		remotes[url] = remote
+	}
+
+	if equalRemotes(l.locked, remotes) {
+		return nil
+	}
+
+	var lock Lock
+
+	for _, remote := range remotes {
+		lock.Remotes = append(lock.Remotes, remote)
+	}
+
+	sort.Slice(lock.Remotes, func(i, j int) bool {
+		return lock.Remotes[i].URL < lock.Remotes[j].URL
+	})
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(l.path, append(data, '\n'), 0644)
+}
+
+func equalRemotes(a map[string]*Remote, b map[string]*Remote) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for url, remote := range a {
+		if other, ok := b[url]; !ok || *other != *remote {
+			return false
+		}
+	}
+
+	return true
+}
+
+func integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func contentKey(integrity string) string {
+	return cache.Key([]byte("remote"), []byte(integrity))
+}
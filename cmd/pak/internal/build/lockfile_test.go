@@ -0,0 +1,102 @@
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockfile(t *testing.T) {
+	content := "a{color:red}"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(content))
+	}))
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="` + server.URL + `/app.css"></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{
+		Root:  root,
+		Jobs:  1,
+		Cache: filepath.Join(root, ".pak-cache"),
+		Lock:  filepath.Join(root, "pak.lock"),
+	}
+
+	urls := []*url.URL{{Path: "/index.html"}}
+
+	_, err := Compile(urls, options, make(Sources))
+	assert.Nil(t, err)
+
+	lockfile, err := openLockfile(options.Lock, nil, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, &Remote{
+		URL:       server.URL + "/app.css",
+		MediaType: "text/css",
+		Integrity: integrity([]byte("a{color:red}")),
+	}, lockfile.locked[server.URL+"/app.css"])
+
+	content = "a{color:blue}"
+
+	_, err = Compile(urls, options, make(Sources))
+	assert.Error(t, err)
+
+	options.UpdateLock = true
+
+	_, err = Compile(urls, options, make(Sources))
+	assert.Nil(t, err)
+
+	server.Close()
+
+	options.UpdateLock = false
+	options.Offline = true
+
+	result, err := Compile(urls, options, make(Sources))
+	assert.Nil(t, err)
+
+	for _, asset := range result.Graph.Assets() {
+		if asset.URL().IsAbs() {
+			assert.Equal(t, "a{color:blue}", string(asset.Data()))
+		}
+	}
+}
+
+func TestLockfileSubset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`.a{font-family:` + r.URL.Query().Get("family") + `}`))
+	}))
+
+	defer server.Close()
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="` + server.URL + `/css?family=A"></head><body></body></html>`,
+		"about.html": `<!doctype html><html><head><link rel="stylesheet" href="` + server.URL + `/css?family=B"></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, Lock: filepath.Join(root, "pak.lock")}
+
+	// Building one entry at a time must keep the files locked by the other.
+	for _, entry := range []string{"/index.html", "/about.html"} {
+		_, err := Compile([]*url.URL{{Path: entry}}, options, make(Sources))
+		assert.Nil(t, err)
+	}
+
+	lockfile, err := openLockfile(options.Lock, nil, false, false)
+	assert.Nil(t, err)
+
+	assert.Len(t, lockfile.locked, 2)
+	assert.Equal(t, integrity([]byte(`.a{font-family:A}`)), lockfile.locked[server.URL+"/css?family=A"].Integrity)
+	assert.Equal(t, integrity([]byte(`.a{font-family:B}`)), lockfile.locked[server.URL+"/css?family=B"].Integrity)
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/kasperisager/pak/pkg/asset"
+)
+
+type (
+	Manifest map[string]*ManifestEntry
+
+	ManifestEntry struct {
+		File      string   `json:"file"`
+		MediaType string   `json:"mediaType"`
+		Size      int      `json:"size"`
+		Hash      string   `json:"hash"`
+		Entry     bool     `json:"entry,omitempty"`
+		Files     []string `json:"files,omitempty"`
+	}
+)
+
+func NewManifest(result *Result) Manifest {
+	manifest := make(Manifest)
+
+	for _, asset := range result.Graph.Assets() {
+		if asset.URL().IsAbs() {
+			continue
+		}
+
+		data := asset.Data()
+		sum := sha256.Sum256(data)
+
+		manifest[result.Origins[asset].String()] = &ManifestEntry{
+			File:      output(asset),
+			MediaType: asset.MediaType(),
+			Size:      len(data),
+			Hash:      hex.EncodeToString(sum[:]),
+		}
+	}
+
+	for _, entry := range result.Entries {
+		manifestEntry, ok := manifest[result.Origins[entry].String()]
+
+		if !ok {
+			continue
+		}
+
+		manifestEntry.Entry = true
+		manifestEntry.Files = needs(result.Graph, entry)
+	}
+
+	return manifest
+}
+
+func ReadManifest(filename string) (Manifest, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func needs(graph *asset.Graph, entry asset.Asset) []string {
+	files := make([]string, 0)
+
+	visited := map[asset.Asset]bool{entry: true}
+
+	var visit func(asset.Asset)
+
+	visit = func(from asset.Asset) {
+		edges, _ := graph.Outgoing(from)
+
+		for _, edge := range edges {
+			related := edge.Asset
+
+			if visited[related] {
+				continue
+			}
+
+			visited[related] = true
+
+			if !related.URL().IsAbs() {
+				files = append(files, output(related))
+			}
+
+			visit(related)
+		}
+	}
+
+	visit(entry)
+
+	sort.Strings(files)
+
+	return files
+}
+
+func output(asset asset.Asset) string {
+	return path.Clean(asset.URL().Path)[1:]
+}
+
+func writeManifest(result *Result, out string, name string) error {
+	data, err := json.MarshalIndent(NewManifest(result), "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(out, name)
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(target, append(data, '\n'), 0644)
+}
@@ -0,0 +1,52 @@
+package build
+
+import (
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css":  `@import "base.css";body{background:url(bg.png)}`,
+		"css/base.css": `a{color:red}`,
+		"css/bg.png":   `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	for _, hash := range []bool{false, true} {
+		options := Options{Root: root, Jobs: 1, Hash: hash, Optimize: 1}
+
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+		assert.Nil(t, err)
+
+		manifest := NewManifest(result)
+
+		assert.Len(t, manifest, 3)
+
+		index := manifest["/index.html"]
+		assert.Equal(t, "index.html", index.File)
+		assert.Equal(t, "text/html", index.MediaType)
+		assert.True(t, index.Entry)
+
+		styles := manifest["/css/app.css"]
+		assert.Equal(t, "text/css", styles.MediaType)
+		assert.NotZero(t, styles.Size)
+		assert.False(t, styles.Entry)
+
+		image := manifest["/css/bg.png"]
+		assert.Equal(t, 3, image.Size)
+
+		assert.Equal(t, []string{styles.File, image.File}, index.Files)
+
+		if hash {
+			assert.NotEqual(t, "css/app.css", styles.File)
+		} else {
+			assert.Equal(t, "css/app.css", styles.File)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package build
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// publicURL parses the base URL that assets, other than HTML pages, are
+// referenced at. Links between pages are left relative as pages are served
+// from the origin rather than the base URL.
+func publicURL(base string) (*url.URL, error) {
+	if base == "" {
+		return nil, nil
+	}
+
+	root, err := url.Parse(base)
+
+	if err != nil {
+		return nil, fmt.Errorf("public URL %q: %s", base, err)
+	}
+
+	if !strings.HasSuffix(root.Path, "/") {
+		root.Path += "/"
+	}
+
+	return root, nil
+}
+
+func published(root *url.URL, location *url.URL) *url.URL {
+	return root.ResolveReference(&url.URL{Path: strings.TrimPrefix(location.Path, "/")})
+}
@@ -0,0 +1,55 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":  `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><link rel="preload" href="about.html"></head><body></body></html>`,
+		"about.html":  `<!doctype html><html><head></head><body></body></html>`,
+		"css/app.css": `body{background:url(bg.png)}`,
+		"css/bg.png":  `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	for _, hash := range []bool{false, true} {
+		options := Options{Root: root, Jobs: 1, Hash: hash, PublicURL: "https://cdn.example.com/assets"}
+
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+		assert.Nil(t, err)
+
+		data := make(map[string]string)
+
+		for _, asset := range result.Graph.Assets() {
+			data[result.Origins[asset].Path] = string(asset.Data())
+
+			if hash && path.Ext(asset.URL().Path) == ".css" {
+				sum := sha256.Sum256(asset.Data())
+				assert.Contains(t, asset.URL().Path, hex.EncodeToString(sum[:4]))
+			}
+		}
+
+		styles := "https://cdn.example.com/assets/css/app"
+		image := "https://cdn.example.com/assets/css/bg"
+
+		if !hash {
+			styles += ".css"
+			image += ".png"
+		}
+
+		assert.Contains(t, data["/index.html"], `href="`+styles)
+		assert.Contains(t, data["/index.html"], `href="about.html"`)
+		assert.Contains(t, data["/css/app.css"], "url("+image)
+		assert.False(t, strings.Contains(data["/css/app.css"], "url(bg"))
+	}
+}
@@ -0,0 +1,49 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reproducible compares the outputs of two builds of the same entries,
+// reporting the files that differ between them.
+func reproducible(a *Result, b *Result) error {
+	files := outputs(a)
+	others := outputs(b)
+
+	var differ []string
+
+	for name, data := range files {
+		if other, ok := others[name]; !ok || !bytes.Equal(data, other) {
+			differ = append(differ, name)
+		}
+	}
+
+	for name := range others {
+		if _, ok := files[name]; !ok {
+			differ = append(differ, name)
+		}
+	}
+
+	if len(differ) == 0 {
+		return nil
+	}
+
+	sort.Strings(differ)
+
+	return fmt.Errorf("build is not reproducible, these files differ between builds: %s", strings.Join(differ, ", "))
+}
+
+func outputs(result *Result) map[string][]byte {
+	files := make(map[string][]byte, result.Graph.Size())
+
+	for _, asset := range result.Graph.Assets() {
+		if url := asset.URL(); !url.IsAbs() {
+			files[url.Path] = asset.Data()
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,78 @@
+package build
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/blob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReproducible(t *testing.T) {
+	result := func(files map[string]string) *Result {
+		graph := asset.NewGraph()
+
+		for name, data := range files {
+			graph.Add(blob.From(&url.URL{Path: name}, []byte(data)))
+		}
+
+		return &Result{Graph: graph}
+	}
+
+	a := result(map[string]string{"/a.css": "a", "/b.css": "b"})
+
+	assert.Nil(t, reproducible(a, result(map[string]string{"/b.css": "b", "/a.css": "a"})))
+
+	err := reproducible(a, result(map[string]string{"/b.css": "c", "/c.css": "c"}))
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "build is not reproducible, these files differ between builds: /a.css, /b.css, /c.css", err.Error())
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	files := map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="app.css"></head><body></body></html>`,
+	}
+
+	imports := ""
+
+	for i := 0; i < 16; i++ {
+		imports += fmt.Sprintf(`@import "%d.css";`, i)
+
+		files[fmt.Sprintf("%d.css", i)] = fmt.Sprintf(`.a%d{background:url(%d.png)}`, i, i)
+		files[fmt.Sprintf("%d.png", i)] = fmt.Sprintf(`png %d`, i)
+	}
+
+	files["app.css"] = imports
+
+	root := site(t, files)
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 4, Hash: true, Optimize: 1}
+
+	var first *Result
+
+	for i := 0; i < 8; i++ {
+		result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		if first == nil {
+			first = result
+			continue
+		}
+
+		assert.Nil(t, reproducible(first, result))
+
+		for j, asset := range result.Graph.Assets() {
+			assert.Equal(t, first.Graph.Assets()[j].URL(), asset.URL())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package build
+
+import (
+	"net/url"
+
+	"github.com/kasperisager/pak/pkg/asset"
+)
+
+// A Session keeps the assets read by one build for the next, such that a
+// rebuild only loads and parses the files that changed since the last build
+// and only relates anew the assets that depend on them. Every build is given
+// its own copies of the kept assets, as compiling a graph changes its assets.
+// A session must not be used by several goroutines at once.
+type Session struct {
+	Sources Sources
+
+	nodes   map[string]*node
+	parents map[*node][]*node
+	stale   map[*node]bool
+}
+
+func NewSession() *Session {
+	return &Session{
+		Sources: make(Sources),
+		nodes:   make(map[string]*node),
+		parents: make(map[*node][]*node),
+		stale:   make(map[*node]bool),
+	}
+}
+
+func (s *Session) Compile(urls []*url.URL, options Options) (*Result, error) {
+	return compile(urls, options, s.Sources, s)
+}
+
+// Invalidate forgets the assets of files that have changed, such that the
+// next build reads them again, and marks the assets that depend on them as
+// stale, such that the next build relates them to the changed assets.
+func (s *Session) Invalidate(root string, files []string) {
+	s.Sources.Invalidate(root, files)
+
+	changed := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		changed[file] = true
+	}
+
+	for key, node := range s.nodes {
+		if !node.url.IsAbs() && changed[filename(node.url, root)] {
+			delete(s.nodes, key)
+			s.invalidate(node)
+		}
+	}
+}
+
+// invalidate marks the nodes that depend on a node, directly or not, as stale.
+func (s *Session) invalidate(node *node) {
+	for _, parent := range s.parents[node] {
+		if !s.stale[parent] {
+			s.stale[parent] = true
+			s.invalidate(parent)
+		}
+	}
+}
+
+// keep replaces the kept nodes by those reached from the entries of a build
+// once it has stopped loading. Nodes that failed to load are not kept, so the
+// next build loads them again, and the nodes that depend on them are stale.
+func (s *Session) keep(entries []*node) {
+	s.nodes = make(map[string]*node)
+	s.parents = make(map[*node][]*node)
+	s.stale = make(map[*node]bool)
+
+	var failed []*node
+
+	visited := make(map[*node]bool)
+
+	var walk func(node *node, keyed bool)
+
+	walk = func(node *node, keyed bool) {
+		visited[node] = true
+
+		if _, ok := node.asset.(asset.Cloner); node.err != nil || !ok {
+			failed = append(failed, node)
+			return
+		}
+
+		if keyed {
+			s.nodes[key(node.url)] = node
+		}
+
+		for _, referenced := range node.referenced {
+			s.parents[referenced] = append(s.parents[referenced], node)
+
+			if !visited[referenced] {
+				walk(referenced, true)
+			}
+		}
+
+		for _, embedded := range node.embedded {
+			s.parents[embedded] = append(s.parents[embedded], node)
+
+			if !visited[embedded] {
+				walk(embedded, false)
+			}
+		}
+	}
+
+	for _, node := range entries {
+		if !visited[node] {
+			walk(node, true)
+		}
+	}
+
+	for _, node := range failed {
+		s.invalidate(node)
+	}
+}
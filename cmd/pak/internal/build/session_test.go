@@ -0,0 +1,93 @@
+package build
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<script type="module" src="main.js"></script>` +
+			`<style>@import "a.css";</style>` +
+			`</head><body></body></html>`,
+		"a.css":   `@import "b.css"; a{color:red}`,
+		"b.css":   `b{color:red}`,
+		"main.js": `import "./util.js";`,
+		"util.js": `"util";`,
+	})
+
+	defer os.RemoveAll(root)
+
+	urls := []*url.URL{{Path: "/index.html"}}
+
+	options := Options{Root: root, Jobs: 4, Optimize: 1}
+
+	session := NewSession()
+
+	result, err := session.Compile(urls, options)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	before := outputs(result)
+
+	kept := make(map[string]*node)
+
+	for key, node := range session.nodes {
+		kept[key] = node
+	}
+
+	// Compiling the graph merges and rewrites its assets, which must not
+	// change the assets kept for the next build.
+	result, err = session.Compile(urls, options)
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, before, outputs(result))
+	}
+
+	for key, node := range kept {
+		assert.True(t, node == session.nodes[key], key)
+	}
+
+	filename := filepath.Join(root, "b.css")
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`b{color:blue}`), 0644))
+
+	session.Invalidate(root, []string{filename})
+
+	result, err = session.Compile(urls, options)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	after := outputs(result)
+
+	assert.NotEqual(t, before["/index.html"], after["/index.html"])
+	assert.Contains(t, string(after["/index.html"]), "b{color:blue}")
+	assert.Equal(t, before["/main.js"], after["/main.js"])
+
+	// Assets that did not change and do not depend on the changed asset are
+	// kept as they were.
+	for _, key := range []string{"/main.js", "/util.js"} {
+		assert.True(t, kept[key] == session.nodes[key], key)
+	}
+
+	// The changed asset is read again.
+	assert.False(t, kept["/b.css"] == session.nodes["/b.css"])
+	assert.False(t, kept["/b.css"].asset == session.nodes["/b.css"].asset)
+
+	// Assets that depend on the changed asset are related anew, but neither
+	// read nor parsed again.
+	for _, key := range []string{"/index.html", "/a.css"} {
+		assert.False(t, kept[key] == session.nodes[key], key)
+		assert.True(t, kept[key].asset == session.nodes[key].asset, key)
+	}
+}
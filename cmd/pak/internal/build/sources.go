@@ -0,0 +1,53 @@
+package build
+
+import (
+	"net/url"
+)
+
+type (
+	Sources map[string]*Source
+
+	Source struct {
+		URL       *url.URL
+		MediaType string
+		Data      []byte
+	}
+)
+
+// key identifies the file that a URL points at. The query is part of this as
+// servers may respond with different files for different queries, such as
+// stylesheets of web fonts, but the fragment is not.
+func key(target *url.URL) string {
+	return (&url.URL{
+		Scheme:   target.Scheme,
+		Host:     target.Host,
+		Path:     target.Path,
+		RawQuery: target.RawQuery,
+	}).String()
+}
+
+func (sources Sources) Files(root string) []string {
+	var files []string
+
+	for _, source := range sources {
+		if !source.URL.IsAbs() {
+			files = append(files, filename(source.URL, root))
+		}
+	}
+
+	return files
+}
+
+func (sources Sources) Invalidate(root string, files []string) {
+	changed := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		changed[file] = true
+	}
+
+	for key, source := range sources {
+		if !source.URL.IsAbs() && changed[filename(source.URL, root)] {
+			delete(sources, key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package build
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourcesQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`.a{font-family:` + r.URL.Query().Get("family") + `}`))
+	}))
+
+	defer server.Close()
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="` + server.URL + `/css?family=A">` +
+			`<link rel="stylesheet" href="` + server.URL + `/css?family=B#foo">` +
+			`<link rel="stylesheet" href="` + server.URL + `/css?family=B">` +
+			`</head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	sources := make(Sources)
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, Options{Root: root, Jobs: 1}, sources)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var data []string
+
+	for _, asset := range result.Graph.Assets() {
+		if asset.URL().IsAbs() {
+			data = append(data, string(asset.Data()))
+		}
+	}
+
+	sort.Strings(data)
+
+	// The fragment does not change the file, so the last two links share it.
+	assert.Equal(t, []string{`.a{font-family:A}`, `.a{font-family:B}`}, data)
+	assert.Len(t, sources, 3)
+}
+
+func TestSourcesInvalidate(t *testing.T) {
+	root := filepath.Join("site", "src")
+
+	sources := Sources{
+		"/index.html":                 {URL: &url.URL{Path: "/index.html"}},
+		"/css/app.css":                {URL: &url.URL{Path: "/css/app.css"}},
+		"https://example.com/app.css": {URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/app.css"}},
+	}
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "css", "app.css"),
+		filepath.Join(root, "index.html"),
+	}, sorted(sources.Files(root)))
+
+	sources.Invalidate(root, []string{filepath.Join(root, "css", "app.css"), filepath.Join(root, "other.css")})
+
+	assert.Len(t, sources, 2)
+	assert.Contains(t, sources, "/index.html")
+	assert.Contains(t, sources, "https://example.com/app.css")
+}
+
+func sorted(files []string) []string {
+	sort.Strings(files)
+	return files
+}
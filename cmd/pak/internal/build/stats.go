@@ -0,0 +1,199 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/html"
+)
+
+type (
+	// Stats describe the assets of an analysis and the relations between them,
+	// as printed by pak graph and written by pak build. Assets are listed by
+	// the URL that they were read from, which is stable between builds such
+	// that two builds can be compared, and relations refer to assets by their
+	// position in the list.
+	Stats struct {
+		Assets    []*StatsAsset    `json:"assets"`
+		Relations []*StatsRelation `json:"relations"`
+	}
+
+	StatsAsset struct {
+		ID         int      `json:"id"`
+		URL        string   `json:"url"`
+		File       string   `json:"file,omitempty"`
+		MediaType  string   `json:"mediaType"`
+		Size       int      `json:"size"`
+		Gzip       int      `json:"gzip"`
+		Hash       string   `json:"hash,omitempty"`
+		Entry      bool     `json:"entry,omitempty"`
+		Partition  []string `json:"partition"`
+		MergedInto *int     `json:"mergedInto,omitempty"`
+		Emitted    bool     `json:"emitted"`
+	}
+
+	StatsRelation struct {
+		From     int    `json:"from"`
+		To       int    `json:"to"`
+		Kind     string `json:"kind"`
+		Location string `json:"location"`
+
+		// Hint is set for relations that only hint at an asset that may be
+		// needed later, such as preloads, rather than one needed by the asset
+		// that relates to it.
+		Hint bool `json:"hint,omitempty"`
+	}
+)
+
+func NewStats(analysis *Analysis) *Stats {
+	ids := make(map[asset.Asset]int, len(analysis.Assets))
+
+	for i, asset := range analysis.Assets {
+		ids[asset] = i
+	}
+
+	entries := make(map[asset.Asset]bool, len(analysis.Entries))
+
+	for _, entry := range analysis.Entries {
+		entries[entry] = true
+	}
+
+	stats := &Stats{
+		Assets:    make([]*StatsAsset, len(analysis.Assets)),
+		Relations: make([]*StatsRelation, len(analysis.Relations)),
+	}
+
+	for i, asset := range analysis.Assets {
+		partition := make([]string, len(analysis.Partitions[asset]))
+
+		for i, entry := range analysis.Partitions[asset] {
+			partition[i] = analysis.Origins[entry].String()
+		}
+
+		stat := &StatsAsset{
+			ID:        i,
+			URL:       analysis.Origins[asset].String(),
+			MediaType: asset.MediaType(),
+			Entry:     entries[asset],
+			Partition: partition,
+			Emitted:   analysis.Emitted(asset),
+		}
+
+		if target, ok := analysis.Merged[asset]; ok {
+			id := ids[target]
+			stat.MergedInto = &id
+		}
+
+		// Only files of the output have a size and a hash.
+		if stat.Emitted {
+			data := asset.Data()
+			sum := sha256.Sum256(data)
+
+			stat.File = output(asset)
+			stat.Size = len(data)
+			stat.Gzip = gzipped(data)
+			stat.Hash = hex.EncodeToString(sum[:])
+		}
+
+		stats.Assets[i] = stat
+	}
+
+	for i, relation := range analysis.Relations {
+		stats.Relations[i] = &StatsRelation{
+			From:     ids[relation.From],
+			To:       ids[relation.To],
+			Kind:     Kind(relation.Relation),
+			Location: analysis.Locations[relation.Relation],
+			Hint:     hint(relation.Relation),
+		}
+	}
+
+	return stats
+}
+
+func ReadStats(filename string) (*Stats, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stats Stats
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func (s *Stats) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func writeStats(result *Result, out string, name string) error {
+	var b bytes.Buffer
+
+	if err := NewStats(analyze(result.Graph, result.Entries, result.Origins)).WriteJSON(&b); err != nil {
+		return err
+	}
+
+	target := filepath.Join(out, name)
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(target, b.Bytes(), 0644)
+}
+
+// hint reports whether a relation only hints at an asset that a page may need
+// later rather than one that the page needs to load.
+func hint(relation asset.Relation) bool {
+	reference, ok := relation.(*html.Reference)
+
+	if !ok || reference.Element == nil || reference.Element.Name != "link" {
+		return false
+	}
+
+	rel := reference.Element.Attribute("rel")
+
+	if rel == nil {
+		return false
+	}
+
+	switch rel.Value {
+	case "preload", "prefetch", "modulepreload":
+		return true
+	}
+
+	return false
+}
+
+// gzipped returns the number of bytes that data takes up once compressed with
+// gzip, as most servers do when sending it.
+func gzipped(data []byte) int {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+
+	return b.Len()
+}
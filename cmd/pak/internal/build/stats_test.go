@@ -0,0 +1,96 @@
+package build
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><link rel="preload" href="css/bg.png"></head><body></body></html>`,
+		"about.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css":  `@import "base.css";body{background:url(bg.png)}`,
+		"css/base.css": `a{color:red}`,
+		"css/bg.png":   `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Jobs: 1, Hash: true, Optimize: 1}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	stats := NewStats(analyze(result.Graph, result.Entries, result.Origins))
+
+	var urls []string
+
+	for i, asset := range stats.Assets {
+		assert.Equal(t, i, asset.ID)
+		urls = append(urls, asset.URL)
+	}
+
+	// The imported stylesheet is merged into the one importing it.
+	assert.Equal(t, []string{
+		"/about.html",
+		"/css/app.css",
+		"/css/bg.png",
+		"/index.html",
+	}, urls)
+
+	styles, image, index := stats.Assets[1], stats.Assets[2], stats.Assets[3]
+
+	assert.True(t, index.Entry)
+	assert.False(t, styles.Entry)
+	assert.True(t, styles.Emitted)
+	assert.Regexp(t, `^css/app\.[0-9a-f]+\.css$`, styles.File)
+	assert.Equal(t, []string{"/index.html", "/about.html"}, styles.Partition)
+	assert.Equal(t, 3, image.Size)
+	assert.NotZero(t, image.Gzip)
+	assert.Len(t, image.Hash, 64)
+
+	var relations []StatsRelation
+
+	for _, relation := range stats.Relations {
+		if relation.From == index.ID {
+			relation.Location = ""
+			relations = append(relations, *relation)
+		}
+	}
+
+	assert.Equal(t, []StatsRelation{
+		{From: index.ID, To: styles.ID, Kind: "link"},
+		{From: index.ID, To: image.ID, Kind: "link", Hint: true},
+	}, relations)
+}
+
+func TestReadStats(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html":  `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css": `body{background:url(bg.png)}`,
+		"css/bg.png":  `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{Root: root, Out: filepath.Join(root, "dist"), Jobs: 1, Hash: true, Stats: "stats.json"}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+
+	if !assert.Nil(t, err) || !assert.Nil(t, Write(result, options)) {
+		return
+	}
+
+	stats, err := ReadStats(filepath.Join(options.Out, "stats.json"))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, NewStats(analyze(result.Graph, result.Entries, result.Origins)), stats)
+	}
+}
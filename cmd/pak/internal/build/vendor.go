@@ -0,0 +1,42 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/asset"
+)
+
+func vendor(graph *asset.Graph, dir string) {
+	locations := make(map[asset.Asset]*url.URL)
+
+	for _, asset := range graph.Assets() {
+		if url := asset.URL(); url.IsAbs() {
+			locations[asset] = vendored(url, dir)
+		}
+	}
+
+	graph.Move(locations)
+}
+
+// vendored returns the location within the output that an external file is
+// copied to. Files that differ only by their query, such as the stylesheets of
+// web fonts, are told apart by a hash of the query in their names.
+func vendored(from *url.URL, dir string) *url.URL {
+	host := strings.Replace(from.Host, ":", "_", -1)
+
+	to := path.Join("/", dir, host, from.Path)
+
+	if from.RawQuery != "" {
+		sum := sha256.Sum256([]byte(from.RawQuery))
+
+		ext := path.Ext(to)
+
+		to = strings.TrimSuffix(to, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+	}
+
+	return &url.URL{Path: to}
+}
@@ -0,0 +1,75 @@
+package build
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVendor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/css/app.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`@font-face{src:url(../fonts/foo.woff)}`))
+
+		case "/fonts/foo.woff":
+			w.Write([]byte("woff"))
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer server.Close()
+
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head><link rel="stylesheet" href="` + server.URL + `/css/app.css"></head><body></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := Options{
+		Root:   root,
+		Out:    filepath.Join(root, "dist"),
+		Vendor: "vendor",
+		Jobs:   1,
+	}
+
+	result, err := Compile([]*url.URL{{Path: "/index.html"}}, options, make(Sources))
+	assert.Nil(t, err)
+
+	assert.Nil(t, Write(result, options))
+
+	host := strings.Replace(strings.TrimPrefix(server.URL, "http://"), ":", "_", -1)
+
+	data, err := ioutil.ReadFile(filepath.Join(options.Out, "index.html"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `href="vendor/`+host+`/css/app.css"`)
+
+	data, err = ioutil.ReadFile(filepath.Join(options.Out, "vendor", host, "css", "app.css"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `url(../fonts/foo.woff)`)
+
+	data, err = ioutil.ReadFile(filepath.Join(options.Out, "vendor", host, "fonts", "foo.woff"))
+	assert.Nil(t, err)
+	assert.Equal(t, "woff", string(data))
+}
+
+func TestVendoredQuery(t *testing.T) {
+	a := vendored(&url.URL{Scheme: "https", Host: "fonts.example.com", Path: "/css", RawQuery: "family=A"}, "vendor")
+	b := vendored(&url.URL{Scheme: "https", Host: "fonts.example.com", Path: "/css", RawQuery: "family=B"}, "vendor")
+
+	assert.Regexp(t, `^/vendor/fonts\.example\.com/css\.[0-9a-f]{8}$`, a.Path)
+	assert.NotEqual(t, a, b)
+
+	assert.Regexp(t, `^/vendor/example\.com_8080/app\.[0-9a-f]{8}\.css$`, vendored(&url.URL{Scheme: "http", Host: "example.com:8080", Path: "/app.css", RawQuery: "v=1"}, "vendor").Path)
+	assert.Equal(t, "/vendor/example.com/app.css", vendored(&url.URL{Scheme: "https", Host: "example.com", Path: "/app.css"}, "vendor").Path)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kasperisager/pak/pkg/cli"
+)
+
+const Dir = ".pak-cache"
+
+type Cache struct {
+	dir string
+}
+
+func Open(dir string) *Cache {
+	return &Cache{dir}
+}
+
+func Key(parts ...[]byte) string {
+	hash := sha256.New()
+
+	for _, part := range parts {
+		// Prefix every part with its length such that moving bytes between
+		// adjacent parts yields a different key.
+		var length [8]byte
+
+		binary.LittleEndian.PutUint64(length[:], uint64(len(part)))
+
+		hash.Write(length[:])
+		hash.Write(part)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(c.path(key))
+
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (c *Cache) Has(key string) bool {
+	_, err := os.Stat(c.path(key))
+	return err == nil
+}
+
+func (c *Cache) Put(key string, data []byte) error {
+	target := c.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first such that concurrent builds never see a
+	// partially written entry.
+	file, err := ioutil.TempFile(filepath.Dir(target), ".tmp-")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), target)
+}
+
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.dir)
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key[2:])
+}
+
+func Command(cmd *cli.Command) {
+	cmd.Usage("<command> [<arguments>]")
+
+	cmd.AddCommand("clean", "Remove everything from the build cache", clean)
+}
+
+func clean(cmd *cli.Command) {
+	dir := cmd.Flag().String("dir", Dir, "The directory of the build cache")
+
+	cmd.Usage("[flags]")
+
+	cmd.HandleFunc(func(args []string) {
+		if err := Open(*dir).Clean(); err != nil {
+			cmd.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/pkg/cli"
+)
+
+type (
+	// A Change is an asset or entry that differs between two builds, along
+	// with its size in either. Sizes are 0 in builds that lack the asset.
+	Change struct {
+		URL       string
+		MediaType string
+		Old       int
+		New       int
+	}
+
+	Report struct {
+		Added   []*Change
+		Removed []*Change
+		Changed []*Change
+
+		// Entries are every entry of either build along with the number of
+		// bytes, compressed with gzip, needed to load it.
+		Entries []*Change
+	}
+)
+
+func Command(cmd *cli.Command) {
+	cmd.Usage("<old stats> <new stats>")
+
+	cmd.HandleFunc(func(args []string) {
+		if len(args) != 2 {
+			cmd.Fatalf("expected two stats files, as written by pak build -stats")
+		}
+
+		from, err := build.ReadStats(args[0])
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		to, err := build.ReadStats(args[1])
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		if err := Compare(from, to).Write(os.Stdout); err != nil {
+			cmd.Fatal(err)
+		}
+	})
+}
+
+// Compare reports the assets that were added, removed or changed between two
+// builds, along with the transfer size of every entry in either.
+func Compare(from *build.Stats, to *build.Stats) *Report {
+	before, after := index(from), index(to)
+
+	report := &Report{}
+
+	for _, asset := range from.Assets {
+		other, ok := after[asset.URL]
+
+		switch {
+		case !ok:
+			report.Removed = append(report.Removed, &Change{asset.URL, asset.MediaType, asset.Size, 0})
+
+		case asset.Hash != other.Hash || asset.Size != other.Size:
+			report.Changed = append(report.Changed, &Change{asset.URL, other.MediaType, asset.Size, other.Size})
+		}
+
+		if asset.Entry || ok && other.Entry {
+			report.Entries = append(report.Entries, &Change{asset.URL, asset.MediaType, transfer(from, before, asset.URL), transfer(to, after, asset.URL)})
+		}
+	}
+
+	for _, asset := range to.Assets {
+		if _, ok := before[asset.URL]; ok {
+			continue
+		}
+
+		report.Added = append(report.Added, &Change{asset.URL, asset.MediaType, 0, asset.Size})
+
+		if asset.Entry {
+			report.Entries = append(report.Entries, &Change{asset.URL, asset.MediaType, 0, transfer(to, after, asset.URL)})
+		}
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return report.Entries[i].URL < report.Entries[j].URL
+	})
+
+	return report
+}
+
+func index(stats *build.Stats) map[string]*build.StatsAsset {
+	assets := make(map[string]*build.StatsAsset, len(stats.Assets))
+
+	for _, asset := range stats.Assets {
+		if _, ok := assets[asset.URL]; !ok {
+			assets[asset.URL] = asset
+		}
+	}
+
+	return assets
+}
+
+// transfer returns the number of bytes, compressed with gzip, needed to load
+// an entry, which is the size of the entry and every asset that it needs.
+// Other entries, such as pages linked to, are loaded on their own and so not
+// counted, and neither are assets that are only preloaded or prefetched.
+func transfer(stats *build.Stats, assets map[string]*build.StatsAsset, entry string) int {
+	asset, ok := assets[entry]
+
+	if !ok || !asset.Entry {
+		return 0
+	}
+
+	outgoing := make(map[int][]*build.StatsRelation)
+
+	for _, relation := range stats.Relations {
+		if !relation.Hint && relation.To >= 0 && relation.To < len(stats.Assets) {
+			outgoing[relation.From] = append(outgoing[relation.From], relation)
+		}
+	}
+
+	size := 0
+
+	visited := make(map[int]bool)
+
+	var visit func(*build.StatsAsset)
+
+	visit = func(asset *build.StatsAsset) {
+		visited[asset.ID] = true
+
+		size += asset.Gzip
+
+		for _, relation := range outgoing[asset.ID] {
+			related := stats.Assets[relation.To]
+
+			if !related.Entry && !visited[related.ID] {
+				visit(related)
+			}
+		}
+	}
+
+	visit(asset)
+
+	return size
+}
+
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+
+	sections := []struct {
+		name    string
+		changes []*Change
+	}{
+		{"added", r.Added},
+		{"removed", r.Removed},
+		{"changed", r.Changed},
+	}
+
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:\n", section.name)
+
+		for _, change := range section.changes {
+			fmt.Fprintf(&b, "  %s (%s) %s\n", change.URL, change.MediaType, change.sizes())
+		}
+
+		fmt.Fprintf(&b, "\n")
+	}
+
+	if len(r.Entries) > 0 {
+		fmt.Fprintf(&b, "entries (gzip):\n")
+
+		for _, entry := range r.Entries {
+			fmt.Fprintf(&b, "  %s %s\n", entry.URL, entry.sizes())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func (c *Change) sizes() string {
+	if c.Old == c.New {
+		return fmt.Sprintf("%d B", c.New)
+	}
+
+	return fmt.Sprintf("%d B -> %d B (%+d B)", c.Old, c.New, c.New-c.Old)
+}
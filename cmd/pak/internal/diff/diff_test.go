@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	from := &build.Stats{
+		Assets: []*build.StatsAsset{
+			{ID: 0, URL: "/about.html", MediaType: "text/html", Size: 50, Gzip: 40, Hash: "a", Entry: true},
+			{ID: 1, URL: "/app.css", MediaType: "text/css", Size: 100, Gzip: 60, Hash: "b"},
+			{ID: 2, URL: "/font.woff2", MediaType: "font/woff2", Size: 300, Gzip: 300, Hash: "g"},
+			{ID: 3, URL: "/index.html", MediaType: "text/html", Size: 60, Gzip: 45, Hash: "c", Entry: true},
+			{ID: 4, URL: "/logo.png", MediaType: "image/png", Size: 200, Gzip: 200, Hash: "d"},
+		},
+		Relations: []*build.StatsRelation{
+			{From: 0, To: 1, Kind: "link"},
+			{From: 3, To: 1, Kind: "link"},
+			{From: 3, To: 0, Kind: "a"},
+			{From: 3, To: 4, Kind: "img"},
+			{From: 3, To: 2, Kind: "link", Hint: true},
+		},
+	}
+
+	to := &build.Stats{
+		Assets: []*build.StatsAsset{
+			{ID: 0, URL: "/about.html", MediaType: "text/html", Size: 50, Gzip: 40, Hash: "a", Entry: true},
+			{ID: 1, URL: "/app.css", MediaType: "text/css", Size: 120, Gzip: 70, Hash: "e"},
+			{ID: 2, URL: "/contact.html", MediaType: "text/html", Size: 40, Gzip: 35, Hash: "f", Entry: true},
+			{ID: 3, URL: "/font.woff2", MediaType: "font/woff2", Size: 300, Gzip: 300, Hash: "g"},
+			{ID: 4, URL: "/index.html", MediaType: "text/html", Size: 60, Gzip: 45, Hash: "c", Entry: true},
+		},
+		Relations: []*build.StatsRelation{
+			{From: 0, To: 1, Kind: "link"},
+			{From: 2, To: 1, Kind: "link"},
+			{From: 4, To: 1, Kind: "link"},
+			{From: 4, To: 0, Kind: "a"},
+			{From: 4, To: 3, Kind: "link", Hint: true},
+		},
+	}
+
+	report := Compare(from, to)
+
+	assert.Equal(t, []*Change{{"/contact.html", "text/html", 0, 40}}, report.Added)
+	assert.Equal(t, []*Change{{"/logo.png", "image/png", 200, 0}}, report.Removed)
+	assert.Equal(t, []*Change{{"/app.css", "text/css", 100, 120}}, report.Changed)
+
+	// Linked pages and preloaded fonts are not part of the transfer size of
+	// an entry, which is compressed.
+	assert.Equal(t, []*Change{
+		{"/about.html", "text/html", 100, 110},
+		{"/contact.html", "text/html", 0, 105},
+		{"/index.html", "text/html", 305, 115},
+	}, report.Entries)
+
+	var b bytes.Buffer
+
+	assert.Nil(t, report.Write(&b))
+
+	assert.Equal(t, `added:
+  /contact.html (text/html) 0 B -> 40 B (+40 B)
+
+removed:
+  /logo.png (image/png) 200 B -> 0 B (-200 B)
+
+changed:
+  /app.css (text/css) 100 B -> 120 B (+20 B)
+
+entries (gzip):
+  /about.html 100 B -> 110 B (+10 B)
+  /contact.html 0 B -> 105 B (+105 B)
+  /index.html 305 B -> 115 B (-190 B)
+`, b.String())
+}
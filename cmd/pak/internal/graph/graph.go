@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/pkg/cli"
+)
+
+func Command(cmd *cli.Command) {
+	flag := cmd.Flag()
+
+	var options build.Options
+
+	options.Define(flag)
+
+	format := flag.String("format", "dot", "The format to print the graph in, either dot or json")
+
+	cmd.Usage("[flags] [entry files]")
+
+	cmd.HandleFunc(func(filenames []string) {
+		if *format != "dot" && *format != "json" {
+			cmd.Fatal(fmt.Errorf("unknown format %q", *format))
+		}
+
+		urls, err := build.Entries(filenames, &options)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		analysis, err := build.Analyze(urls, options, make(build.Sources))
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		stats := build.NewStats(analysis)
+
+		if *format == "json" {
+			err = stats.WriteJSON(os.Stdout)
+		} else {
+			err = WriteDOT(os.Stdout, stats)
+		}
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+	})
+}
+
+// WriteDOT writes the assets of stats in the Graphviz DOT language, grouping
+// assets by partition and drawing assets that are merged into others with
+// dashed lines.
+func WriteDOT(w io.Writer, stats *build.Stats) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph pak {\n")
+	fmt.Fprintf(&b, "  node [shape=box];\n")
+
+	var partitions []string
+
+	clusters := make(map[string][]*build.StatsAsset)
+
+	for _, asset := range stats.Assets {
+		partition := strings.Join(asset.Partition, ", ")
+
+		if _, ok := clusters[partition]; !ok {
+			partitions = append(partitions, partition)
+		}
+
+		clusters[partition] = append(clusters[partition], asset)
+	}
+
+	for i, partition := range partitions {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "    label=%s;\n", strconv.Quote(partition))
+
+		for _, asset := range clusters[partition] {
+			label := asset.URL + "\n" + asset.MediaType
+
+			var style []string
+
+			if asset.Entry {
+				style = append(style, "bold")
+			}
+
+			if asset.MergedInto != nil {
+				style = append(style, "dashed")
+				label += "\nmerged into " + stats.Assets[*asset.MergedInto].URL
+			}
+
+			fmt.Fprintf(&b, "    n%d [label=%s", asset.ID, strconv.Quote(label))
+
+			if len(style) > 0 {
+				fmt.Fprintf(&b, ", style=%s", strconv.Quote(strings.Join(style, ",")))
+			}
+
+			fmt.Fprintf(&b, "];\n")
+		}
+
+		fmt.Fprintf(&b, "  }\n")
+	}
+
+	for _, relation := range stats.Relations {
+		fmt.Fprintf(
+			&b,
+			"  n%d -> n%d [label=%s, tooltip=%s];\n",
+			relation.From,
+			relation.To,
+			strconv.Quote(relation.Kind),
+			strconv.Quote(relation.Location),
+		)
+	}
+
+	fmt.Fprintf(&b, "}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
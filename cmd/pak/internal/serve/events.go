@@ -0,0 +1,99 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	eventsPath = "/_pak/events"
+	clientPath = "/_pak/client.js"
+)
+
+// The client is loaded by every served page and either reloads the page or,
+// if only stylesheets changed, swaps the stylesheets of the page in place. It
+// is served as a script of its own rather than inlined into pages so that the
+// Content Security Policies of pages, which allow scripts of their own origin,
+// also allow the client.
+const client = `(function(){
+var source=new EventSource("` + eventsPath + `");
+source.onmessage=function(event){
+if(event.data!=="css"){location.reload();return}
+var links=document.querySelectorAll('link[rel="stylesheet"]');
+Array.prototype.forEach.call(links,function(link){
+var next=link.cloneNode();
+var url=new URL(link.href);
+url.searchParams.set("pak",Date.now());
+next.href=url.href;
+next.onload=function(){link.parentNode.removeChild(link)};
+link.parentNode.insertBefore(next,link.nextSibling)
+})
+}
+})()`
+
+type events struct {
+	lock    sync.Mutex
+	clients map[chan string]bool
+}
+
+func newEvents() *events {
+	return &events{clients: make(map[chan string]bool)}
+}
+
+func (e *events) subscribe() chan string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	client := make(chan string, 1)
+	e.clients[client] = true
+
+	return client
+}
+
+func (e *events) unsubscribe(client chan string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	delete(e.clients, client)
+}
+
+func (e *events) publish(event string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for client := range e.clients {
+		select {
+		case client <- event:
+		default:
+		}
+	}
+}
+
+func (e *events) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	client := e.subscribe()
+	defer e.unsubscribe(client)
+
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event := <-client:
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			flusher.Flush()
+		}
+	}
+}
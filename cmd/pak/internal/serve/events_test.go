@@ -0,0 +1,49 @@
+package serve
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	events := newEvents()
+
+	server := httptest.NewServer(events)
+
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	defer response.Body.Close()
+
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	// The client is subscribed before the headers of the stream are sent.
+	events.publish("css")
+
+	reader := bufio.NewReader(response.Body)
+
+	line, err := reader.ReadString('\n')
+
+	assert.Nil(t, err)
+	assert.Equal(t, "data: css\n", line)
+
+	line, err = reader.ReadString('\n')
+
+	assert.Nil(t, err)
+	assert.Equal(t, "\n", line)
+
+	events.publish("reload")
+
+	line, _ = reader.ReadString('\n')
+
+	assert.Equal(t, "data: reload\n", line)
+}
@@ -0,0 +1,233 @@
+package serve
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/cmd/pak/internal/watch"
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/cli"
+
+	"github.com/kasperisager/pak/pkg/asset/css"
+	"github.com/kasperisager/pak/pkg/asset/html"
+	"github.com/kasperisager/pak/pkg/asset/html/ast"
+	"github.com/kasperisager/pak/pkg/asset/js"
+)
+
+type (
+	server struct {
+		lock   sync.RWMutex
+		files  map[string]*file
+		events *events
+	}
+
+	file struct {
+		mediaType string
+		data      []byte
+	}
+)
+
+func Command(cmd *cli.Command) {
+	flag := cmd.Flag()
+
+	var options build.Options
+
+	options.Define(flag)
+
+	var (
+		addr     = flag.String("addr", "localhost:8080", "The address to listen on")
+		interval = flag.Duration("interval", 500*time.Millisecond, "The interval between checks for changed files")
+	)
+
+	cmd.Usage("[flags] [entry files]")
+
+	cmd.HandleFunc(func(filenames []string) {
+		urls, err := build.Entries(filenames, &options)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		server := &server{events: newEvents()}
+
+		sources := make(build.Sources)
+
+		watcher := watch.NewWatcher(filenames)
+
+		if _, err := server.rebuild(urls, options, sources, watcher); err != nil {
+			cmd.Error(err)
+		}
+
+		go func() {
+			cmd.Logf("serving on http://%s", *addr)
+
+			if err := http.ListenAndServe(*addr, server); err != nil {
+				cmd.Fatal(err)
+			}
+		}()
+
+		for {
+			time.Sleep(*interval)
+
+			changed := watcher.Poll()
+
+			if len(changed) == 0 {
+				continue
+			}
+
+			sources.Invalidate(options.Root, changed)
+
+			result, err := server.rebuild(urls, options, sources, watcher)
+
+			if err != nil {
+				cmd.Error(err)
+				continue
+			}
+
+			if !options.Hash && linkedStyles(result, options.Root, changed) {
+				server.events.publish("css")
+			} else {
+				server.events.publish("reload")
+			}
+		}
+	})
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case eventsPath:
+		s.events.ServeHTTP(w, r)
+		return
+
+	case clientPath:
+		w.Header().Set("Content-Type", js.MediaType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(client))
+		return
+	}
+
+	path := r.URL.Path
+
+	if strings.HasSuffix(path, "/") {
+		path += "index.html"
+	}
+
+	s.lock.RLock()
+	file, ok := s.files[path]
+	s.lock.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if file.mediaType != "" {
+		w.Header().Set("Content-Type", file.mediaType)
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+
+	w.Write(file.data)
+}
+
+func (s *server) rebuild(
+	urls []*url.URL,
+	options build.Options,
+	sources build.Sources,
+	watcher *watch.Watcher,
+) (*build.Result, error) {
+	result, err := build.Compile(urls, options, sources)
+
+	if err != nil {
+		watcher.Add(sources.Files(options.Root)...)
+		return nil, err
+	}
+
+	watcher.Reset(sources.Files(options.Root)...)
+
+	files := make(map[string]*file, result.Graph.Size())
+
+	for _, asset := range result.Graph.Assets() {
+		url := asset.URL()
+
+		if url.IsAbs() {
+			continue
+		}
+
+		switch asset := asset.(type) {
+		case *html.Asset:
+			inject(asset.Document)
+		}
+
+		files[url.Path] = &file{
+			mediaType: asset.MediaType(),
+			data:      asset.Data(),
+		}
+	}
+
+	s.lock.Lock()
+	s.files = files
+	s.lock.Unlock()
+
+	return result, nil
+}
+
+func inject(document *ast.Document) {
+	parent := document.Root
+
+	if body, ok := document.Root.Find(ast.ByName("body")).Next(); ok {
+		parent = body
+	}
+
+	parent.Children = append(parent.Children, &ast.Element{
+		Name: "script",
+		Attributes: []*ast.Attribute{
+			{Name: "src", Value: clientPath},
+		},
+	})
+}
+
+// linkedStyles reports whether every changed file is a stylesheet that pages
+// load through links of their own, such that swapping the stylesheets of the
+// pages shows the change. Stylesheets that were merged into others, inlined
+// into pages or imported by other stylesheets require a reload.
+func linkedStyles(result *build.Result, root string, changed []string) bool {
+	assets := make(map[string]asset.Asset, len(result.Origins))
+
+	for asset, origin := range result.Origins {
+		if result.Graph.Has(asset) {
+			assets[origin.String()] = asset
+		}
+	}
+
+	for _, file := range changed {
+		name, err := filepath.Rel(root, file)
+
+		if err != nil {
+			return false
+		}
+
+		stylesheet, ok := assets["/"+filepath.ToSlash(name)]
+
+		if !ok || stylesheet.MediaType() != css.MediaType {
+			return false
+		}
+
+		edges, _ := result.Graph.Incoming(stylesheet)
+
+		for _, edge := range edges {
+			reference, ok := edge.Relation.(*html.Reference)
+
+			if !ok || reference.Element == nil || reference.Element.Name != "link" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,133 @@
+package serve
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/cmd/pak/internal/watch"
+	"github.com/stretchr/testify/assert"
+)
+
+func site(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	}
+
+	return root
+}
+
+func TestServe(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head>` +
+			`<link rel="stylesheet" href="linked.css">` +
+			`<link rel="stylesheet" href="importing.css">` +
+			`<link rel="stylesheet" href="inlined.css">` +
+			`</head><body></body></html>`,
+		"linked.css":    `a{color:red}`,
+		"importing.css": `@import "imported.css";`,
+		"imported.css":  `b{color:red}`,
+		"inlined.css":   `i{}`,
+		"image.png":     `png`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := build.Options{Root: root, Jobs: 1, Optimize: 1, Inline: 4}
+
+	server := &server{events: newEvents()}
+
+	watcher := watch.NewWatcher([]string{filepath.Join(root, "index.html")})
+
+	result, err := server.rebuild([]*url.URL{{Path: "/index.html"}}, options, make(build.Sources), watcher)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, test := range []struct {
+		files []string
+		swap  bool
+	}{
+		{[]string{"linked.css"}, true},
+		{[]string{"linked.css", "importing.css"}, true},
+		{[]string{"imported.css"}, false},
+		{[]string{"inlined.css"}, false},
+		{[]string{"linked.css", "index.html"}, false},
+		{[]string{"image.png"}, false},
+	} {
+		changed := make([]string, len(test.files))
+
+		for i, file := range test.files {
+			changed[i] = filepath.Join(root, file)
+		}
+
+		assert.Equal(t, test.swap, linkedStyles(result, root, changed), "%v", test.files)
+	}
+
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "text/html", response.Header().Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(response.Body.String(), `<script src="`+clientPath+`"></script></body></html>`))
+
+	response = httptest.NewRecorder()
+
+	server.ServeHTTP(response, httptest.NewRequest("GET", clientPath, nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/javascript", response.Header().Get("Content-Type"))
+	assert.Equal(t, client, response.Body.String())
+
+	response = httptest.NewRecorder()
+
+	server.ServeHTTP(response, httptest.NewRequest("GET", "/missing.css", nil))
+
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestServeCSP(t *testing.T) {
+	root := site(t, map[string]string{
+		"index.html": `<!doctype html><html><head></head><body><script>"page"</script></body></html>`,
+	})
+
+	defer os.RemoveAll(root)
+
+	options := build.Options{Root: root, Jobs: 1, CSP: "meta"}
+
+	server := &server{events: newEvents()}
+
+	watcher := watch.NewWatcher([]string{filepath.Join(root, "index.html")})
+
+	_, err := server.rebuild([]*url.URL{{Path: "/index.html"}}, options, make(build.Sources), watcher)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	response := httptest.NewRecorder()
+
+	server.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	// The policy only allows inline scripts that were part of the build, but
+	// allows every script of the page's own origin, including the client.
+	body := response.Body.String()
+
+	assert.Contains(t, body, `script-src 'self' 'sha256-`)
+	assert.Contains(t, body, `<script src="`+clientPath+`"></script>`)
+	assert.NotContains(t, body, client)
+}
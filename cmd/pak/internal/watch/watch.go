@@ -0,0 +1,83 @@
+package watch
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/pkg/cli"
+)
+
+// Command rebuilds the entries whenever a file that they depend on changes.
+// Only the changed files are read and parsed again, and only the assets that
+// depend on them are related anew, before the graph is compiled and written.
+func Command(cmd *cli.Command) {
+	flag := cmd.Flag()
+
+	var options build.Options
+
+	options.Define(flag)
+
+	flag.StringVar(&options.Out, "o", "dist", "The directory to write files to")
+	flag.StringVar(&options.Manifest, "manifest", "", "The file, relative to the output, to write a manifest of the output to")
+
+	interval := flag.Duration("interval", 500*time.Millisecond, "The interval between checks for changed files")
+
+	cmd.Usage("[flags] [entry files]")
+
+	cmd.HandleFunc(func(filenames []string) {
+		urls, err := build.Entries(filenames, &options)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		session := build.NewSession()
+
+		watcher := NewWatcher(filenames)
+
+		rebuild(cmd, urls, options, session, watcher)
+
+		for {
+			time.Sleep(*interval)
+
+			changed := watcher.Poll()
+
+			if len(changed) == 0 {
+				continue
+			}
+
+			session.Invalidate(options.Root, changed)
+
+			rebuild(cmd, urls, options, session, watcher)
+		}
+	})
+}
+
+func rebuild(
+	cmd *cli.Command,
+	urls []*url.URL,
+	options build.Options,
+	session *build.Session,
+	watcher *Watcher,
+) {
+	start := time.Now()
+
+	result, err := session.Compile(urls, options)
+
+	if err == nil {
+		err = build.Write(result, options)
+	}
+
+	if err != nil {
+		cmd.Error(err)
+
+		// The file that broke the build might no longer be among the sources,
+		// so keep watching everything that was watched before.
+		watcher.Add(session.Sources.Files(options.Root)...)
+	} else {
+		watcher.Reset(session.Sources.Files(options.Root)...)
+
+		cmd.Logf("built %d files in %s", result.Graph.Size(), time.Since(start).Round(time.Millisecond))
+	}
+}
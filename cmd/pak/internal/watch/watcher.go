@@ -0,0 +1,93 @@
+package watch
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+type (
+	Watcher struct {
+		entries []string
+		stamps  map[string]stamp
+	}
+
+	stamp struct {
+		modTime time.Time
+		size    int64
+	}
+)
+
+func NewWatcher(entries []string) *Watcher {
+	watcher := &Watcher{
+		entries: entries,
+		stamps:  make(map[string]stamp),
+	}
+
+	watcher.Add(entries...)
+
+	return watcher
+}
+
+func (w *Watcher) Add(files ...string) {
+	for _, file := range files {
+		if _, ok := w.stamps[file]; !ok {
+			w.stamps[file] = stat(file)
+		}
+	}
+}
+
+func (w *Watcher) Reset(files ...string) {
+	stamps := w.stamps
+
+	w.stamps = make(map[string]stamp, len(files))
+
+	// Files that are still watched keep their existing stamp such that changes
+	// made while rebuilding are picked up by the next poll.
+	for _, file := range append(files, w.entries...) {
+		if stamp, ok := stamps[file]; ok {
+			w.stamps[file] = stamp
+		} else {
+			w.stamps[file] = stat(file)
+		}
+	}
+}
+
+func (w *Watcher) Files() []string {
+	files := make([]string, 0, len(w.stamps))
+
+	for file := range w.stamps {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+func (w *Watcher) Poll() []string {
+	var changed []string
+
+	for file, previous := range w.stamps {
+		current := stat(file)
+
+		if !current.modTime.Equal(previous.modTime) || current.size != previous.size {
+			w.stamps[file] = current
+			changed = append(changed, file)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+func stat(file string) stamp {
+	info, err := os.Stat(file)
+
+	if err != nil {
+		return stamp{}
+	}
+
+	return stamp{info.ModTime(), info.Size()}
+}
@@ -0,0 +1,48 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(dir)
+
+	index := filepath.Join(dir, "index.html")
+	styles := filepath.Join(dir, "app.css")
+	missing := filepath.Join(dir, "missing.css")
+
+	assert.Nil(t, ioutil.WriteFile(index, []byte("index"), 0644))
+	assert.Nil(t, ioutil.WriteFile(styles, []byte("a"), 0644))
+
+	watcher := NewWatcher([]string{index})
+
+	watcher.Add(styles, missing)
+
+	assert.Equal(t, []string{styles, index, missing}, watcher.Files())
+	assert.Empty(t, watcher.Poll())
+
+	// Changes are detected by size as well as by modification time, as the
+	// latter may not change between quick writes.
+	assert.Nil(t, ioutil.WriteFile(styles, []byte("ab"), 0644))
+	assert.Nil(t, ioutil.WriteFile(missing, []byte("a"), 0644))
+
+	assert.Equal(t, []string{styles, missing}, watcher.Poll())
+	assert.Empty(t, watcher.Poll())
+
+	// Entries are watched even when no longer part of the build.
+	watcher.Reset(styles)
+
+	assert.Equal(t, []string{styles, index}, watcher.Files())
+
+	assert.Nil(t, os.Remove(index))
+
+	assert.Equal(t, []string{index}, watcher.Poll())
+}
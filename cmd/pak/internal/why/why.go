@@ -0,0 +1,306 @@
+package why
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/cli"
+)
+
+type (
+	// A Hop is a single step along a path from an entry, going from an asset
+	// to the asset that it relates to.
+	Hop struct {
+		From     asset.Asset
+		Relation asset.Relation
+	}
+
+	Path []Hop
+)
+
+func Command(cmd *cli.Command) {
+	flag := cmd.Flag()
+
+	var options build.Options
+
+	options.Define(flag)
+
+	flag.StringVar(&options.Out, "o", "dist", "The directory that files of the output are looked up in")
+	flag.StringVar(&options.Manifest, "manifest", "", "The manifest, relative to the output, to look up the names of files of the output in")
+	flag.StringVar(&options.Stats, "stats", "", "The stats, relative to the output, to look up the names of files of the output in")
+
+	cmd.Usage("[flags] <file> [entry files]")
+
+	cmd.HandleFunc(func(args []string) {
+		if len(args) < 2 {
+			cmd.Fatalf("expected a file and at least one entry file")
+		}
+
+		urls, err := build.Entries(args[1:], &options)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		target, err := resolve(args[0], options.Root)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		sources := make(build.Sources)
+
+		analysis, err := build.Analyze(urls, options, sources)
+
+		if err != nil {
+			cmd.Fatal(err)
+		}
+
+		assets := Find(analysis, target)
+
+		// Files of the output might have been renamed or vendored, so these are
+		// looked up by the file that they were built from.
+		output, err := resolve(args[0], options.Out)
+
+		if err == nil && len(assets) == 0 && !strings.HasPrefix(output.Path, "/../") {
+			origin, ok, err := lookup(urls, options, sources, output)
+
+			if err != nil {
+				cmd.Fatal(err)
+			}
+
+			if ok {
+				assets = Find(analysis, origin)
+			}
+
+			if len(assets) == 0 && options.Manifest == "" && options.Stats == "" {
+				cmd.Fatalf(
+					"%s: not part of the build; files of the output are only found when given the -hash, -vendor and -public-url flags they were built with, or their -manifest or -stats",
+					args[0],
+				)
+			}
+		}
+
+		if len(assets) == 0 {
+			cmd.Fatalf("%s: not part of the build", args[0])
+		}
+
+		w := os.Stdout
+
+		for i, asset := range assets {
+			if i != 0 {
+				fmt.Fprintln(w)
+			}
+
+			Explain(w, analysis, asset)
+		}
+	})
+}
+
+// lookup returns the URL that a file of the output was built from. The name
+// of the file is looked up in the manifest or stats of the output if one is
+// given, and otherwise in a build of the entries, which only names files as
+// the output does if it is given the same options.
+func lookup(
+	urls []*url.URL,
+	options build.Options,
+	sources build.Sources,
+	output *url.URL,
+) (*url.URL, bool, error) {
+	origins := make(map[string]string)
+
+	switch {
+	case options.Manifest != "":
+		manifest, err := build.ReadManifest(filepath.Join(options.Out, options.Manifest))
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		for origin, entry := range manifest {
+			origins["/"+entry.File] = origin
+		}
+
+	case options.Stats != "":
+		stats, err := build.ReadStats(filepath.Join(options.Out, options.Stats))
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, asset := range stats.Assets {
+			if asset.File != "" {
+				origins["/"+asset.File] = asset.URL
+			}
+		}
+
+	default:
+		result, err := build.Compile(urls, options, sources)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		origin, ok := Origin(result, output)
+
+		return origin, ok, nil
+	}
+
+	origin, ok := origins[output.Path]
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	target, err := url.Parse(origin)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return target, true, nil
+}
+
+func resolve(file string, root string) (*url.URL, error) {
+	if target, err := url.Parse(file); err == nil && target.IsAbs() {
+		return target, nil
+	}
+
+	file, err := filepath.Rel(root, file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Path: "/" + filepath.ToSlash(file)}, nil
+}
+
+// Find returns the assets of an analysis that were read from the given URL.
+// This can be several assets as embedded assets share the URL of the asset
+// that they are embedded in.
+func Find(analysis *build.Analysis, target *url.URL) []asset.Asset {
+	return analysis.ByURL(target)
+}
+
+// Origin returns the URL that a file of the output was built from, which is
+// not the URL of the file if it was renamed or vendored.
+func Origin(result *build.Result, output *url.URL) (*url.URL, bool) {
+	if asset, ok := result.Graph.ByURL(&url.URL{Path: output.Path}); ok {
+		return result.Origins[asset], true
+	}
+
+	return nil, false
+}
+
+// Paths returns every path from an entry to the given asset, walking the
+// incoming relations of the asset.
+func Paths(analysis *build.Analysis, target asset.Asset) []Path {
+	entries := make(map[asset.Asset]bool, len(analysis.Entries))
+
+	for _, entry := range analysis.Entries {
+		entries[entry] = true
+	}
+
+	var paths []Path
+
+	visited := make(map[asset.Asset]bool)
+
+	var walk func(asset.Asset, Path)
+
+	walk = func(to asset.Asset, path Path) {
+		if entries[to] {
+			found := make(Path, len(path))
+
+			for i, hop := range path {
+				found[len(path)-1-i] = hop
+			}
+
+			paths = append(paths, found)
+		}
+
+		visited[to] = true
+
+		for _, hop := range incoming(analysis, to) {
+			if !visited[hop.From] {
+				walk(hop.From, append(path, hop))
+			}
+		}
+
+		visited[to] = false
+	}
+
+	walk(target, nil)
+
+	return paths
+}
+
+func incoming(analysis *build.Analysis, to asset.Asset) []Hop {
+	edges, _ := analysis.Graph.Incoming(to)
+
+	hops := make([]Hop, 0, len(edges))
+
+	for _, edge := range edges {
+		relation, from := edge.Relation, edge.Asset
+
+		hops = append(hops, Hop{from, relation})
+	}
+
+	sort.Slice(hops, func(i, j int) bool {
+		a, b := hops[i].From.URL().String(), hops[j].From.URL().String()
+
+		if a != b {
+			return a < b
+		}
+
+		return analysis.Locations[hops[i].Relation] < analysis.Locations[hops[j].Relation]
+	})
+
+	return hops
+}
+
+func Explain(w io.Writer, analysis *build.Analysis, target asset.Asset) {
+	fmt.Fprintf(w, "%s (%s)\n", target.URL(), target.MediaType())
+
+	switch {
+	case analysis.Emitted(target):
+		fmt.Fprintf(w, "  emitted as its own file\n")
+
+	case target.URL().IsAbs():
+		fmt.Fprintf(w, "  left as an external file\n")
+
+	default:
+		parent := target
+
+		for {
+			next, ok := analysis.Merged[parent]
+
+			if !ok {
+				break
+			}
+
+			parent = next
+		}
+
+		fmt.Fprintf(w, "  merged into %s (%s)\n", parent.URL(), parent.MediaType())
+	}
+
+	paths := Paths(analysis, target)
+
+	for i, path := range paths {
+		fmt.Fprintf(w, "\npath %d of %d:\n", i+1, len(paths))
+
+		for _, hop := range path {
+			fmt.Fprintf(w, "  %s\n", hop.From.URL())
+			fmt.Fprintf(w, "    %s: %s\n", build.Kind(hop.Relation), analysis.Locations[hop.Relation])
+		}
+
+		fmt.Fprintf(w, "  %s\n", target.URL())
+	}
+}
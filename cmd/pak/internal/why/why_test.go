@@ -0,0 +1,195 @@
+package why
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"index.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><link rel="stylesheet" href="css/base.css"></head><body></body></html>`,
+		"about.html":   `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css":  `@import "base.css";`,
+		"css/base.css": `a{color:red}`,
+	}
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	}
+
+	urls := []*url.URL{{Path: "/index.html"}, {Path: "/about.html"}}
+
+	analysis, err := build.Analyze(urls, build.Options{Root: root, Jobs: 1, Optimize: 1}, make(build.Sources))
+	assert.Nil(t, err)
+
+	assets := Find(analysis, &url.URL{Path: "/css/base.css"})
+	assert.Len(t, assets, 1)
+
+	var rendered []string
+
+	for _, path := range Paths(analysis, assets[0]) {
+		var hops string
+
+		for _, hop := range path {
+			hops += hop.From.URL().Path + " " + analysis.Locations[hop.Relation] + " "
+		}
+
+		rendered = append(rendered, hops)
+	}
+
+	assert.Equal(t, []string{
+		`/about.html <link rel="stylesheet" href="css/app.css"> /css/app.css @import "base.css" `,
+		`/index.html <link rel="stylesheet" href="css/app.css"> /css/app.css @import "base.css" `,
+		`/index.html <link rel="stylesheet" href="css/base.css"> `,
+	}, rendered)
+
+	var b bytes.Buffer
+
+	Explain(&b, analysis, assets[0])
+
+	assert.Contains(t, b.String(), "merged into /css/app.css (text/css)")
+	assert.Contains(t, b.String(), "path 3 of 3:")
+}
+
+func TestOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`b{color:blue}`))
+	}))
+
+	defer server.Close()
+
+	root, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"index.html":  `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"><link rel="stylesheet" href="` + server.URL + `/lib.css?v=1"></head><body></body></html>`,
+		"css/app.css": `a{color:red}`,
+	}
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	}
+
+	options := build.Options{Root: root, Out: filepath.Join(root, "dist"), Vendor: "vendor", Hash: true, Jobs: 1}
+
+	result, err := build.Compile([]*url.URL{{Path: "/index.html"}}, options, make(build.Sources))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	origins := make(map[string]string)
+
+	for _, asset := range result.Graph.Assets() {
+		output, err := resolve(filepath.Join(options.Out, filepath.FromSlash(asset.URL().Path)), options.Out)
+		assert.Nil(t, err)
+
+		origin, ok := Origin(result, output)
+
+		if assert.True(t, ok, output.Path) {
+			origins[output.Path] = origin.String()
+		}
+	}
+
+	assert.Len(t, origins, 3)
+	assert.Equal(t, "/index.html", origins["/index.html"])
+
+	for output, origin := range origins {
+		switch {
+		case origin == "/css/app.css":
+			assert.Regexp(t, `^/css/app\.[0-9a-f]+\.css$`, output)
+
+		case origin != "/index.html":
+			assert.Equal(t, server.URL+"/lib.css?v=1", origin)
+			assert.Regexp(t, `^/vendor/.+/lib\.[0-9a-f]+\.[0-9a-f]+\.css$`, output)
+		}
+	}
+
+	_, ok := Origin(result, &url.URL{Path: "/css/app.css"})
+
+	assert.False(t, ok)
+}
+
+func TestLookup(t *testing.T) {
+	root, err := ioutil.TempDir("", "pak")
+	assert.Nil(t, err)
+
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"index.html":  `<!doctype html><html><head><link rel="stylesheet" href="css/app.css"></head><body></body></html>`,
+		"css/app.css": `a{color:red}`,
+	}
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.Nil(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	}
+
+	urls := []*url.URL{{Path: "/index.html"}}
+
+	options := build.Options{
+		Root:     root,
+		Out:      filepath.Join(root, "dist"),
+		Manifest: "manifest.json",
+		Stats:    "stats.json",
+		Hash:     true,
+		Jobs:     1,
+	}
+
+	result, err := build.Compile(urls, options, make(build.Sources))
+
+	if !assert.Nil(t, err) || !assert.Nil(t, build.Write(result, options)) {
+		return
+	}
+
+	stylesheets := result.Graph.ByMediaType("text/css")
+
+	if !assert.Len(t, stylesheets, 1) {
+		return
+	}
+
+	output := stylesheets[0].URL()
+
+	// The names of files are read from the manifest or stats, so the flags
+	// that shaped them need not be given again.
+	for _, options := range []build.Options{
+		{Root: root, Out: options.Out, Manifest: options.Manifest},
+		{Root: root, Out: options.Out, Stats: options.Stats},
+	} {
+		origin, ok, err := lookup(urls, options, make(build.Sources), output)
+
+		if assert.Nil(t, err) && assert.True(t, ok) {
+			assert.Equal(t, "/css/app.css", origin.String())
+		}
+	}
+
+	_, ok, err := lookup(urls, build.Options{Root: root, Out: options.Out, Jobs: 1}, make(build.Sources), output)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
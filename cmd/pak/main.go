@@ -4,6 +4,12 @@ import (
 	"os"
 
 	"github.com/kasperisager/pak/cmd/pak/internal/build"
+	"github.com/kasperisager/pak/cmd/pak/internal/cache"
+	"github.com/kasperisager/pak/cmd/pak/internal/diff"
+	"github.com/kasperisager/pak/cmd/pak/internal/graph"
+	"github.com/kasperisager/pak/cmd/pak/internal/serve"
+	"github.com/kasperisager/pak/cmd/pak/internal/watch"
+	"github.com/kasperisager/pak/cmd/pak/internal/why"
 	"github.com/kasperisager/pak/pkg/cli"
 )
 
@@ -11,6 +17,12 @@ func main() {
 	app := cli.New("pak", "<command> [<arguments>]")
 
 	app.AddCommand("build", "Build the thing!", build.Command)
+	app.AddCommand("watch", "Build the thing whenever it changes!", watch.Command)
+	app.AddCommand("serve", "Serve the thing and reload it whenever it changes!", serve.Command)
+	app.AddCommand("graph", "Print the dependency graph of the thing", graph.Command)
+	app.AddCommand("why", "Explain why a file is part of the thing", why.Command)
+	app.AddCommand("diff", "Compare the output of two builds of the thing", diff.Command)
+	app.AddCommand("cache", "Manage the build cache", cache.Command)
 
 	app.Run(os.Args[1:])
 }
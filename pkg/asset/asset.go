@@ -12,6 +12,15 @@ type (
 		References() []Reference
 		Embeds() []Embed
 		Merge(Asset, Relation) bool
+		Rewrite(*url.URL)
+	}
+
+	// A Cloner is an asset that can be copied, such that merging into or
+	// rewriting the copy and its relations leaves the original as it is. The
+	// copy lists its references and embeds in the same order as the original.
+	Cloner interface {
+		Asset
+		Clone() Asset
 	}
 
 	Relation interface {
@@ -38,3 +38,13 @@ func (a *Asset) Data() []byte {
 func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	return false
 }
+
+func (a *Asset) Rewrite(to *url.URL) {
+	a.url = to
+}
+
+// Clone returns a copy of the asset that shares its immutable data.
+func (a *Asset) Clone() asset.Asset {
+	clone := *a
+	return &clone
+}
@@ -2,8 +2,8 @@ package css
 
 import (
 	"bytes"
-	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/kasperisager/pak/pkg/asset"
 	"github.com/kasperisager/pak/pkg/asset/css/ast"
@@ -11,6 +11,7 @@ import (
 	"github.com/kasperisager/pak/pkg/asset/css/scanner"
 	"github.com/kasperisager/pak/pkg/asset/css/token"
 	"github.com/kasperisager/pak/pkg/asset/css/writer"
+	"github.com/kasperisager/pak/pkg/diagnostic"
 )
 
 type (
@@ -22,6 +23,8 @@ type (
 	Reference struct {
 		url         *url.URL
 		Rule        ast.Rule
+		Declaration *ast.Declaration
+		Token       int
 		Conditional bool
 	}
 )
@@ -34,6 +37,11 @@ func From(url *url.URL, data []byte) (*Asset, error) {
 	tokens, err := scanner.Scan(runes)
 
 	if err != nil {
+		switch err := err.(type) {
+		case scanner.SyntaxError:
+			return nil, diagnostic.From(url, runes, err.Offset, err.Message)
+		}
+
 		return nil, err
 	}
 
@@ -42,8 +50,15 @@ func From(url *url.URL, data []byte) (*Asset, error) {
 	if err != nil {
 		switch err := err.(type) {
 		case parser.SyntaxError:
-			t := tokens[err.Offset]
-			return nil, fmt.Errorf("%s: %#v %d", err, t, token.Offset(t))
+			// The offset of a parser error is the index of the offending token
+			// rather than an offset into the source.
+			offset := len(runes)
+
+			if err.Offset < len(tokens) {
+				offset = token.Offset(tokens[err.Offset])
+			}
+
+			return nil, diagnostic.From(url, runes, offset, err.Message)
 		}
 
 		return nil, err
@@ -88,6 +103,17 @@ func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	return false
 }
 
+func (a *Asset) Rewrite(to *url.URL) {
+	a.url = to
+}
+
+// Clone returns a copy of the asset with its own copy of the rules and
+// declarations of the style sheet, which are changed by merges and rewrites.
+// Selectors and conditions are never changed and so are shared.
+func (a *Asset) Clone() asset.Asset {
+	return &Asset{a.url, cloneStyleSheet(a.StyleSheet)}
+}
+
 func (r *Reference) VisitRelation(v asset.RelationVisitor) {
 	v.Reference(r)
 }
@@ -103,6 +129,18 @@ func (r *Reference) Rewrite(to *url.URL) {
 	case *ast.ImportRule:
 		rule.URL = to
 	}
+
+	if r.Declaration != nil {
+		switch t := r.Declaration.Value[r.Token].(type) {
+		case token.Url:
+			t.Value = to.String()
+			r.Declaration.Value[r.Token] = t
+
+		case token.String:
+			t.Value = to.String()
+			r.Declaration.Value[r.Token] = t
+		}
+	}
 }
 
 func (r *Reference) Flags() asset.Flags {
@@ -125,11 +163,66 @@ func collectReferences(
 				Conditional: conditional,
 			})
 
+		case *ast.StyleRule:
+			references = collectDeclarationReferences(rule.Declarations, references)
+
+		case *ast.FontFaceRule:
+			references = collectDeclarationReferences(rule.Declarations, references)
+
+		case *ast.KeyframesRule:
+			for _, block := range rule.Blocks {
+				references = collectDeclarationReferences(block.Declarations, references)
+			}
+
 		case *ast.MediaRule:
-			collectReferences(base, rule.StyleSheet, references)
+			references = collectReferences(base, rule.StyleSheet, references)
 
 		case *ast.SupportsRule:
-			collectReferences(base, rule.StyleSheet, references)
+			references = collectReferences(base, rule.StyleSheet, references)
+		}
+	}
+
+	return references
+}
+
+func collectDeclarationReferences(
+	declarations []*ast.Declaration,
+	references []asset.Reference,
+) []asset.Reference {
+	for _, declaration := range declarations {
+		for i, t := range declaration.Value {
+			var value string
+
+			switch t := t.(type) {
+			case token.Url:
+				value = t.Value
+
+			case token.String:
+				if i > 0 {
+					switch previous := declaration.Value[i-1].(type) {
+					case token.Function:
+						if strings.EqualFold(previous.Value, "url") {
+							value = t.Value
+						}
+					}
+				}
+			}
+
+			if value == "" || strings.HasPrefix(value, "#") {
+				continue
+			}
+
+			url, err := url.Parse(value)
+
+			if err != nil || url.Scheme == "data" {
+				continue
+			}
+
+			references = append(references, &Reference{
+				url:         url,
+				Declaration: declaration,
+				Token:       i,
+			})
 		}
 	}
 
@@ -156,3 +249,65 @@ func mergeRule(rule ast.Rule, from *Asset, to *Asset) bool {
 
 	return false
 }
+
+func cloneStyleSheet(styleSheet *ast.StyleSheet) *ast.StyleSheet {
+	rules := make([]ast.Rule, len(styleSheet.Rules))
+
+	for i, rule := range styleSheet.Rules {
+		switch rule := rule.(type) {
+		case *ast.StyleRule:
+			clone := *rule
+			clone.Declarations = cloneDeclarations(rule.Declarations)
+			rules[i] = &clone
+
+		case *ast.ImportRule:
+			clone := *rule
+			rules[i] = &clone
+
+		case *ast.MediaRule:
+			clone := *rule
+			clone.StyleSheet = cloneStyleSheet(rule.StyleSheet)
+			rules[i] = &clone
+
+		case *ast.FontFaceRule:
+			clone := *rule
+			clone.Declarations = cloneDeclarations(rule.Declarations)
+			rules[i] = &clone
+
+		case *ast.KeyframesRule:
+			clone := *rule
+			clone.Blocks = make([]*ast.KeyframeBlock, len(rule.Blocks))
+
+			for j, block := range rule.Blocks {
+				clone.Blocks[j] = &ast.KeyframeBlock{
+					Selector:     block.Selector,
+					Declarations: cloneDeclarations(block.Declarations),
+				}
+			}
+
+			rules[i] = &clone
+
+		case *ast.SupportsRule:
+			clone := *rule
+			clone.StyleSheet = cloneStyleSheet(rule.StyleSheet)
+			rules[i] = &clone
+
+		default:
+			rules[i] = rule
+		}
+	}
+
+	return &ast.StyleSheet{Rules: rules}
+}
+
+func cloneDeclarations(declarations []*ast.Declaration) []*ast.Declaration {
+	clones := make([]*ast.Declaration, len(declarations))
+
+	for i, declaration := range declarations {
+		clone := *declaration
+		clone.Value = append([]token.Token{}, declaration.Value...)
+		clones[i] = &clone
+	}
+
+	return clones
+}
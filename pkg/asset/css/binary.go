@@ -0,0 +1,82 @@
+package css
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/url"
+
+	"github.com/kasperisager/pak/pkg/asset/css/ast"
+	"github.com/kasperisager/pak/pkg/asset/css/token"
+)
+
+func init() {
+	gob.RegisterName("css/ast.StyleRule", &ast.StyleRule{})
+	gob.RegisterName("css/ast.ImportRule", &ast.ImportRule{})
+	gob.RegisterName("css/ast.MediaRule", &ast.MediaRule{})
+	gob.RegisterName("css/ast.FontFaceRule", &ast.FontFaceRule{})
+	gob.RegisterName("css/ast.KeyframesRule", &ast.KeyframesRule{})
+	gob.RegisterName("css/ast.SupportsRule", &ast.SupportsRule{})
+	gob.RegisterName("css/ast.PageRule", &ast.PageRule{})
+	gob.RegisterName("css/ast.Declaration", &ast.Declaration{})
+	gob.RegisterName("css/ast.IdSelector", &ast.IdSelector{})
+	gob.RegisterName("css/ast.ClassSelector", &ast.ClassSelector{})
+	gob.RegisterName("css/ast.AttributeSelector", &ast.AttributeSelector{})
+	gob.RegisterName("css/ast.TypeSelector", &ast.TypeSelector{})
+	gob.RegisterName("css/ast.PseudoSelector", &ast.PseudoSelector{})
+	gob.RegisterName("css/ast.CompoundSelector", &ast.CompoundSelector{})
+	gob.RegisterName("css/ast.ComplexSelector", &ast.ComplexSelector{})
+	gob.RegisterName("css/ast.MediaQuery", &ast.MediaQuery{})
+	gob.RegisterName("css/ast.MediaOperation", &ast.MediaOperation{})
+	gob.RegisterName("css/ast.MediaFeature", &ast.MediaFeature{})
+	gob.RegisterName("css/ast.MediaNegation", &ast.MediaNegation{})
+	gob.RegisterName("css/ast.MediaValuePlain", &ast.MediaValuePlain{})
+	gob.RegisterName("css/ast.MediaValueRange", &ast.MediaValueRange{})
+	gob.RegisterName("css/ast.KeyframeBlock", &ast.KeyframeBlock{})
+	gob.RegisterName("css/ast.SupportsOperation", &ast.SupportsOperation{})
+	gob.RegisterName("css/ast.SupportsFeature", &ast.SupportsFeature{})
+	gob.RegisterName("css/ast.SupportsNegation", &ast.SupportsNegation{})
+	gob.RegisterName("css/ast.PageSelector", &ast.PageSelector{})
+	gob.RegisterName("css/ast.PageDeclaration", &ast.PageDeclaration{})
+	gob.RegisterName("css/ast.PageMargin", &ast.PageMargin{})
+
+	gob.RegisterName("css/token.Ident", token.Ident{})
+	gob.RegisterName("css/token.Function", token.Function{})
+	gob.RegisterName("css/token.AtKeyword", token.AtKeyword{})
+	gob.RegisterName("css/token.Hash", token.Hash{})
+	gob.RegisterName("css/token.String", token.String{})
+	gob.RegisterName("css/token.Url", token.Url{})
+	gob.RegisterName("css/token.Delim", token.Delim{})
+	gob.RegisterName("css/token.Number", token.Number{})
+	gob.RegisterName("css/token.Percentage", token.Percentage{})
+	gob.RegisterName("css/token.Dimension", token.Dimension{})
+	gob.RegisterName("css/token.Whitespace", token.Whitespace{})
+	gob.RegisterName("css/token.Colon", token.Colon{})
+	gob.RegisterName("css/token.Semicolon", token.Semicolon{})
+	gob.RegisterName("css/token.CloseCurly", token.CloseCurly{})
+	gob.RegisterName("css/token.OpenCurly", token.OpenCurly{})
+	gob.RegisterName("css/token.CloseParen", token.CloseParen{})
+	gob.RegisterName("css/token.OpenParen", token.OpenParen{})
+	gob.RegisterName("css/token.CloseSquare", token.CloseSquare{})
+	gob.RegisterName("css/token.OpenSquare", token.OpenSquare{})
+	gob.RegisterName("css/token.Comma", token.Comma{})
+}
+
+func FromBinary(url *url.URL, data []byte) (*Asset, error) {
+	var styleSheet ast.StyleSheet
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&styleSheet); err != nil {
+		return nil, err
+	}
+
+	return &Asset{url, &styleSheet}, nil
+}
+
+func (a *Asset) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := gob.NewEncoder(&b).Encode(a.StyleSheet); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
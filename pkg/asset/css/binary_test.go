@@ -0,0 +1,33 @@
+package css
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinary(t *testing.T) {
+	var tests = []string{
+		`@import "foo.css" screen;`,
+		`#foo.bar>baz[qux="x"]{color:red !important;background:url(foo.png)}`,
+		`@media (min-width:100px){a{b:c}}`,
+		`@font-face{font-family:foo;src:url("foo.woff")}`,
+	}
+
+	for _, test := range tests {
+		url := &url.URL{Path: "/foo.css"}
+
+		asset, err := From(url, []byte(test))
+		assert.Nil(t, err, test)
+
+		data, err := asset.MarshalBinary()
+		assert.Nil(t, err, test)
+
+		decoded, err := FromBinary(url, data)
+		assert.Nil(t, err, test)
+
+		assert.Equal(t, asset.StyleSheet, decoded.StyleSheet, test)
+		assert.Equal(t, asset.Data(), decoded.Data(), test)
+	}
+}
@@ -707,6 +707,13 @@ func parseSelectorList(offset int, tokens []token.Token) (int, []token.Token, []
 				return offset, tokens, selectors, err
 			}
 
+			if selector == nil {
+				return offset, tokens, selectors, SyntaxError{
+					Offset:  offset,
+					Message: "unexpected token, expected selector",
+				}
+			}
+
 			selectors = append(selectors, selector)
 		}
 	}
@@ -552,3 +552,31 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.styleSheet, ast, test.input)
 	}
 }
+
+func TestParseError(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   SyntaxError
+	}{
+		{
+			"b }",
+			SyntaxError{Offset: 2, Message: "unexpected token, expected selector"},
+		},
+		{
+			"b",
+			SyntaxError{Offset: 1, Message: "unexpected token, expected selector"},
+		},
+		{
+			"a{color:red",
+			SyntaxError{Offset: 5, Message: "unexpected token, expected ident"},
+		},
+	}
+
+	for _, test := range tests {
+		tokens, err := scanner.Scan([]rune(test.input))
+		assert.Nil(t, err, test.input)
+
+		_, err = Parse(tokens)
+		assert.Equal(t, test.err, err, test.input)
+	}
+}
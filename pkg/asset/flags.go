@@ -1,5 +1,11 @@
 package asset
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type (
 	Flags []*flag
 
@@ -54,3 +60,15 @@ func (f Flags) Delete(key string) Flags {
 
 	return f
 }
+
+func (f Flags) String() string {
+	pairs := make([]string, len(f))
+
+	for i, flag := range f {
+		pairs[i] = fmt.Sprintf("%s=%v", flag.key, flag.value)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ";")
+}
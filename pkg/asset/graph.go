@@ -1,115 +1,256 @@
 package asset
 
+import (
+	"net/url"
+	"sort"
+	"sync"
+)
+
 type (
+	// A Graph is a set of assets and the relations between them. Assets and
+	// relations are iterated in the order they were added so that anything
+	// built from a graph is the same between runs. Assets are indexed by URL
+	// and media type, so assets must be moved through the graph rather than
+	// rewritten directly for them to be found by URL.
+	//
+	// A graph is safe for concurrent use, with each method seeing the graph
+	// either before or after any other. The assets and relations of a graph
+	// are not locked, however, so methods that change them, such as Merge and
+	// Move, must not run while other goroutines read the assets themselves.
+	// Such readers, and readers that need a consistent view across several
+	// calls, should take a Snapshot instead.
 	Graph struct {
-		nodes map[Asset]bool
-		edges map[Asset]edges
+		lock sync.RWMutex
+
+		nodes map[Asset]*node
+
+		// The assets in the order they were added, with deleted assets left
+		// as nil until enough of them have accumulated to compact the list.
+		order   []Asset
+		deleted int
+
+		urls       map[string][]Asset
+		mediaTypes map[string]map[Asset]bool
+	}
+
+	// An Edge is a relation to or from an asset along with the asset at the
+	// other end of it.
+	Edge struct {
+		Relation Relation
+		Asset    Asset
 	}
 
-	edges struct {
-		incoming relations
-		outgoing relations
+	node struct {
+		index    int
+		incoming *relations
+		outgoing *relations
 	}
 
-	relations map[Relation]Asset
+	// relations are the relations to or from an asset. Each relation leads to
+	// a single asset, but the same two assets may be related any number of
+	// times. Like assets, removed relations are left as nil in their order
+	// until it is compacted.
+	relations struct {
+		order   []Relation
+		deleted int
+		index   map[Relation]int
+		assets  map[Relation]Asset
+		pairs   map[Asset][]Relation
+	}
 )
 
 func NewGraph() *Graph {
 	return &Graph{
-		nodes: make(map[Asset]bool),
-		edges: make(map[Asset]edges),
+		nodes:      make(map[Asset]*node),
+		urls:       make(map[string][]Asset),
+		mediaTypes: make(map[string]map[Asset]bool),
 	}
 }
 
 func (g *Graph) Size() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
 	return len(g.nodes)
 }
 
 func (g *Graph) Assets() []Asset {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.assets()
+}
+
+func (g *Graph) assets() []Asset {
 	assets := make([]Asset, 0, len(g.nodes))
 
-	for asset := range g.nodes {
-		assets = append(assets, asset)
+	for _, asset := range g.order {
+		if asset != nil {
+			assets = append(assets, asset)
+		}
 	}
 
 	return assets
 }
 
 func (g *Graph) Has(asset Asset) bool {
-	return g.nodes[asset]
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.has(asset)
+}
+
+func (g *Graph) has(asset Asset) bool {
+	_, ok := g.nodes[asset]
+	return ok
 }
 
 func (g *Graph) Add(asset Asset) bool {
-	if g.Has(asset) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.has(asset) {
 		return false
 	}
 
-	g.nodes[asset] = true
-	g.edges[asset] = edges{
-		incoming: make(relations),
-		outgoing: make(relations),
+	g.nodes[asset] = &node{
+		index:    len(g.order),
+		incoming: newRelations(),
+		outgoing: newRelations(),
 	}
 
+	g.order = append(g.order, asset)
+
+	key := urlKey(asset.URL())
+
+	g.urls[key] = append(g.urls[key], asset)
+
+	mediaType := asset.MediaType()
+
+	if g.mediaTypes[mediaType] == nil {
+		g.mediaTypes[mediaType] = make(map[Asset]bool)
+	}
+
+	g.mediaTypes[mediaType][asset] = true
+
 	return true
 }
 
 func (g *Graph) Delete(asset Asset) bool {
-	if !g.Has(asset) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.remove(asset)
+}
+
+func (g *Graph) remove(asset Asset) bool {
+	node, ok := g.nodes[asset]
+
+	if !ok {
 		return false
 	}
 
-	for _, edge := range g.edges[asset].incoming {
-		for relation, found := range g.edges[edge].outgoing {
-			if found == asset {
-				delete(g.edges[edge].outgoing, relation)
-			}
-		}
+	for _, edge := range node.incoming.list() {
+		g.nodes[edge.Asset].outgoing.remove(edge.Relation, asset)
 	}
 
-	for _, edge := range g.edges[asset].outgoing {
-		for relation, found := range g.edges[edge].incoming {
-			if found == asset {
-				delete(g.edges[edge].incoming, relation)
-			}
-		}
+	for _, edge := range node.outgoing.list() {
+		g.nodes[edge.Asset].incoming.remove(edge.Relation, asset)
 	}
 
+	g.unindex(asset, asset.URL())
+
+	delete(g.mediaTypes[asset.MediaType()], asset)
 	delete(g.nodes, asset)
-	delete(g.edges, asset)
+
+	g.order[node.index] = nil
+	g.deleted++
+
+	if g.deleted > len(g.order)/2 {
+		g.compact()
+	}
 
 	return true
 }
 
 func (g *Graph) Relate(from Asset, to Asset, relation Relation) bool {
-	if !g.Has(from) || !g.Has(to) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.relate(from, to, relation)
+}
+
+func (g *Graph) relate(from Asset, to Asset, relation Relation) bool {
+	if !g.has(from) || !g.has(to) {
+		return false
+	}
+
+	// A relation leads to a single asset, so relating it anew replaces the
+	// asset it used to lead to.
+	if previous, ok := g.nodes[from].outgoing.assets[relation]; ok && previous != to {
+		g.nodes[previous].incoming.remove(relation, from)
+	}
+
+	g.nodes[from].outgoing.add(relation, to)
+	g.nodes[to].incoming.add(relation, from)
+
+	return true
+}
+
+func (g *Graph) Unrelate(from Asset, to Asset, relation Relation) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.has(from) || !g.has(to) || g.nodes[from].outgoing.assets[relation] != to {
 		return false
 	}
 
-	g.edges[from].outgoing[relation] = to
-	g.edges[to].incoming[relation] = from
+	g.nodes[from].outgoing.remove(relation, to)
+	g.nodes[to].incoming.remove(relation, from)
 
 	return true
 }
 
+// Relation returns the first relation from one asset to another.
 func (g *Graph) Relation(from Asset, to Asset) (Relation, bool) {
-	if edges, ok := g.Outgoing(from); ok {
-		for relation, asset := range edges {
-			if to == asset {
-				return relation, true
-			}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return g.relation(from, to)
+}
+
+func (g *Graph) relation(from Asset, to Asset) (Relation, bool) {
+	if node, ok := g.nodes[from]; ok {
+		if relations := node.outgoing.pairs[to]; len(relations) > 0 {
+			return relations[0], true
 		}
 	}
 
 	return nil, false
 }
 
+// Relations returns every relation from one asset to another in the order
+// they were added.
+func (g *Graph) Relations(from Asset, to Asset) []Relation {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if node, ok := g.nodes[from]; ok {
+		relations := node.outgoing.pairs[to]
+		return append(make([]Relation, 0, len(relations)), relations...)
+	}
+
+	return nil
+}
+
 func (g *Graph) Roots() []Asset {
-	roots := make([]Asset, 0)
+	g.lock.RLock()
+	defer g.lock.RUnlock()
 
-	for asset := range g.nodes {
-		indegree, _ := g.Indegree(asset)
+	roots := make([]Asset, 0)
 
-		if indegree == 0 {
+	for _, asset := range g.assets() {
+		if g.nodes[asset].incoming.len() == 0 {
 			roots = append(roots, asset)
 		}
 	}
@@ -118,12 +259,13 @@ func (g *Graph) Roots() []Asset {
 }
 
 func (g *Graph) Leaves() []Asset {
-	leaves := make([]Asset, 0)
+	g.lock.RLock()
+	defer g.lock.RUnlock()
 
-	for asset := range g.nodes {
-		outdegree, _ := g.Outdegree(asset)
+	leaves := make([]Asset, 0)
 
-		if outdegree == 0 {
+	for _, asset := range g.assets() {
+		if g.nodes[asset].outgoing.len() == 0 {
 			leaves = append(leaves, asset)
 		}
 	}
@@ -131,52 +273,58 @@ func (g *Graph) Leaves() []Asset {
 	return leaves
 }
 
-func (g *Graph) Incoming(asset Asset) (map[Relation]Asset, bool) {
-	if edges, ok := g.edges[asset]; ok {
-		result := make(map[Relation]Asset, len(edges.incoming))
+// Incoming returns the relations to an asset in the order they were added.
+func (g *Graph) Incoming(asset Asset) ([]Edge, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
 
-		for relation, asset := range edges.incoming {
-			result[relation] = asset
-		}
-
-		return result, true
+	if node, ok := g.nodes[asset]; ok {
+		return node.incoming.list(), true
 	}
 
 	return nil, false
 }
 
 func (g *Graph) Indegree(asset Asset) (int, bool) {
-	if edges, ok := g.edges[asset]; ok {
-		return len(edges.incoming), true
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if node, ok := g.nodes[asset]; ok {
+		return node.incoming.len(), true
 	}
 
 	return 0, false
 }
 
-func (g *Graph) Outgoing(asset Asset) (map[Relation]Asset, bool) {
-	if edges, ok := g.edges[asset]; ok {
-		result := make(map[Relation]Asset, len(edges.outgoing))
+// Outgoing returns the relations from an asset in the order they were added.
+func (g *Graph) Outgoing(asset Asset) ([]Edge, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
 
-		for relation, asset := range edges.outgoing {
-			result[relation] = asset
-		}
-
-		return result, true
+	if node, ok := g.nodes[asset]; ok {
+		return node.outgoing.list(), true
 	}
 
 	return nil, false
 }
 
 func (g *Graph) Outdegree(asset Asset) (int, bool) {
-	if edges, ok := g.edges[asset]; ok {
-		return len(edges.outgoing), true
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if node, ok := g.nodes[asset]; ok {
+		return node.outgoing.len(), true
 	}
 
 	return 0, false
 }
 
+// Lookup returns the first asset that matches a query. Every asset is
+// checked, so ByURL and ByMediaType should be used when possible. The query
+// runs on the assets of the graph as they were when Lookup was called and may
+// itself use the graph.
 func (g *Graph) Lookup(query Query) (Asset, bool) {
-	for asset := range g.nodes {
+	for _, asset := range g.Assets() {
 		if query(asset) {
 			return asset, true
 		}
@@ -185,12 +333,48 @@ func (g *Graph) Lookup(query Query) (Asset, bool) {
 	return nil, false
 }
 
+// ByURL returns the first asset added at a URL, ignoring its query and
+// fragment.
+func (g *Graph) ByURL(url *url.URL) (Asset, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if assets := g.urls[urlKey(url)]; len(assets) > 0 {
+		return assets[0], true
+	}
+
+	return nil, false
+}
+
+// ByMediaType returns the assets of a media type in the order they were added.
+func (g *Graph) ByMediaType(mediaType string) []Asset {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	found := g.mediaTypes[mediaType]
+
+	assets := make([]Asset, 0, len(found))
+
+	for asset := range found {
+		assets = append(assets, asset)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return g.nodes[assets[i]].index < g.nodes[assets[j]].index
+	})
+
+	return assets
+}
+
 func (g *Graph) Merge(target Asset, source Asset) bool {
-	if !g.Has(target) || !g.Has(source) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.has(target) || !g.has(source) {
 		return false
 	}
 
-	relation, ok := g.Relation(target, source)
+	relation, ok := g.relation(target, source)
 
 	if !ok {
 		return false
@@ -202,45 +386,326 @@ func (g *Graph) Merge(target Asset, source Asset) bool {
 		return false
 	}
 
-	if edges, ok := g.Outgoing(source); ok {
-		for relation, related := range edges {
-			if related != target {
-				switch relation := relation.(type) {
-				case Reference:
-					relation.Rewrite(
-						rebase(
-							relation.URL(),
-							source.URL(),
-							target.URL(),
-						),
-					)
-				}
-
-				g.Relate(target, related, relation)
+	for _, edge := range g.nodes[source].outgoing.list() {
+		relation, related := edge.Relation, edge.Asset
+
+		if related != target {
+			switch relation := relation.(type) {
+			case Reference:
+				relation.Rewrite(
+					rebase(
+						relation.URL(),
+						source.URL(),
+						target.URL(),
+					),
+				)
 			}
+
+			g.relate(target, related, relation)
 		}
 	}
 
-	if edges, ok := g.Incoming(source); ok {
-		for relation, related := range edges {
-			if related != target {
-				switch relation := relation.(type) {
-				case Reference:
-					relation.Rewrite(
-						rewrite(
-							related.URL(),
-							relation.URL(),
-							target.URL(),
-						),
-					)
-				}
+	for _, edge := range g.nodes[source].incoming.list() {
+		relation, related := edge.Relation, edge.Asset
+
+		if related != target {
+			switch relation := relation.(type) {
+			case Reference:
+				relation.Rewrite(
+					rewrite(
+						related.URL(),
+						relation.URL(),
+						target.URL(),
+					),
+				)
+			}
 
-				g.Relate(related, target, relation)
+			g.relate(related, target, relation)
+		}
+	}
+
+	g.remove(source)
+
+	return true
+}
+
+func (g *Graph) Rewrite(asset Asset, to *url.URL) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.has(asset) {
+		return false
+	}
+
+	g.move(map[Asset]*url.URL{asset: to})
+
+	return true
+}
+
+func (g *Graph) Move(locations map[Asset]*url.URL) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.move(locations)
+}
+
+func (g *Graph) move(locations map[Asset]*url.URL) {
+	moved := make([]Asset, 0, len(locations))
+
+	for asset := range locations {
+		if g.has(asset) {
+			moved = append(moved, asset)
+		}
+	}
+
+	sort.Slice(moved, func(i, j int) bool {
+		return g.nodes[moved[i]].index < g.nodes[moved[j]].index
+	})
+
+	for _, asset := range moved {
+		to := locations[asset]
+
+		for _, edge := range g.nodes[asset].outgoing.list() {
+			relation, related := edge.Relation, edge.Asset
+
+			if _, ok := locations[related]; ok {
+				continue
+			}
+
+			switch relation := relation.(type) {
+			case Reference:
+				relation.Rewrite(
+					rebase(
+						relation.URL(),
+						asset.URL(),
+						to,
+					),
+				)
+			}
+		}
+
+		for _, edge := range g.nodes[asset].incoming.list() {
+			relation, related := edge.Relation, edge.Asset
+
+			base := related.URL()
+
+			if moved, ok := locations[related]; ok {
+				base = moved
+			}
+
+			switch relation := relation.(type) {
+			case Reference:
+				relation.Rewrite(
+					rewrite(
+						base,
+						relation.URL(),
+						to,
+					),
+				)
 			}
 		}
 	}
 
-	g.Delete(source)
+	for _, asset := range moved {
+		g.unindex(asset, asset.URL())
+
+		asset.Rewrite(locations[asset])
+
+		key := urlKey(asset.URL())
+
+		g.urls[key] = append(g.urls[key], asset)
+	}
+}
+
+// Publish rewrites the references to an asset to point at the given URL
+// without moving the asset itself, such as when the asset is served from a
+// different location than the one it is written to.
+func (g *Graph) Publish(asset Asset, at *url.URL) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.has(asset) {
+		return false
+	}
+
+	for _, edge := range g.nodes[asset].incoming.list() {
+		switch relation := edge.Relation.(type) {
+		case Reference:
+			relation.Rewrite(
+				rewrite(
+					edge.Asset.URL(),
+					relation.URL(),
+					at,
+				),
+			)
+		}
+	}
 
 	return true
 }
+
+// Snapshot returns a copy of the graph that is unaffected by later changes to
+// the graph and to its assets and relations. The assets and relations of the
+// snapshot are copies that keep the URLs, media types, data and flags of the
+// originals as they were when the snapshot was taken, but not their types.
+func (g *Graph) Snapshot() *Graph {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	snapshot := NewGraph()
+
+	assets := make(map[Asset]*frozenAsset, len(g.nodes))
+
+	for _, asset := range g.assets() {
+		assets[asset] = freezeAsset(asset)
+		snapshot.Add(assets[asset])
+	}
+
+	relations := make(map[Relation]Relation)
+
+	for _, asset := range g.assets() {
+		from := assets[asset]
+
+		for _, edge := range g.nodes[asset].outgoing.list() {
+			relation, ok := relations[edge.Relation]
+
+			if !ok {
+				relation = freezeRelation(edge.Relation)
+				relations[edge.Relation] = relation
+			}
+
+			snapshot.relate(from, assets[edge.Asset], relation)
+
+			switch relation := relation.(type) {
+			case Reference:
+				from.references = append(from.references, relation)
+
+			case Embed:
+				from.embeds = append(from.embeds, relation)
+			}
+		}
+	}
+
+	return snapshot
+}
+
+func (g *Graph) unindex(asset Asset, url *url.URL) {
+	key := urlKey(url)
+
+	assets := g.urls[key]
+
+	for i, found := range assets {
+		if found == asset {
+			assets = append(assets[:i:i], assets[i+1:]...)
+			break
+		}
+	}
+
+	if len(assets) == 0 {
+		delete(g.urls, key)
+	} else {
+		g.urls[key] = assets
+	}
+}
+
+func (g *Graph) compact() {
+	order := make([]Asset, 0, len(g.nodes))
+
+	for _, asset := range g.order {
+		if asset != nil {
+			g.nodes[asset].index = len(order)
+			order = append(order, asset)
+		}
+	}
+
+	g.order = order
+	g.deleted = 0
+}
+
+func urlKey(url *url.URL) string {
+	return url.Scheme + "://" + url.Host + url.Path
+}
+
+func newRelations() *relations {
+	return &relations{
+		index:  make(map[Relation]int),
+		assets: make(map[Relation]Asset),
+		pairs:  make(map[Asset][]Relation),
+	}
+}
+
+func (r *relations) len() int {
+	return len(r.assets)
+}
+
+func (r *relations) add(relation Relation, asset Asset) {
+	if previous, ok := r.assets[relation]; !ok {
+		r.index[relation] = len(r.order)
+		r.order = append(r.order, relation)
+	} else if previous == asset {
+		return
+	} else {
+		r.unpair(relation, previous)
+	}
+
+	r.assets[relation] = asset
+	r.pairs[asset] = append(r.pairs[asset], relation)
+}
+
+// remove removes a relation if it still relates to the given asset.
+func (r *relations) remove(relation Relation, asset Asset) {
+	if found, ok := r.assets[relation]; !ok || found != asset {
+		return
+	}
+
+	r.unpair(relation, asset)
+
+	r.order[r.index[relation]] = nil
+	r.deleted++
+
+	delete(r.assets, relation)
+	delete(r.index, relation)
+
+	if r.deleted > len(r.order)/2 {
+		order := make([]Relation, 0, len(r.assets))
+
+		for _, relation := range r.order {
+			if relation != nil {
+				r.index[relation] = len(order)
+				order = append(order, relation)
+			}
+		}
+
+		r.order = order
+		r.deleted = 0
+	}
+}
+
+func (r *relations) unpair(relation Relation, asset Asset) {
+	pairs := r.pairs[asset]
+
+	for i, found := range pairs {
+		if found == relation {
+			pairs = append(pairs[:i:i], pairs[i+1:]...)
+			break
+		}
+	}
+
+	if len(pairs) == 0 {
+		delete(r.pairs, asset)
+	} else {
+		r.pairs[asset] = pairs
+	}
+}
+
+func (r *relations) list() []Edge {
+	edges := make([]Edge, 0, len(r.assets))
+
+	for _, relation := range r.order {
+		if relation != nil {
+			edges = append(edges, Edge{relation, r.assets[relation]})
+		}
+	}
+
+	return edges
+}
@@ -0,0 +1,469 @@
+package asset
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	testAsset struct {
+		url *url.URL
+	}
+
+	testReference struct {
+		url *url.URL
+	}
+
+	// A testBundle is an asset that other assets can be merged into.
+	testBundle struct {
+		url  *url.URL
+		data []byte
+	}
+)
+
+func (a *testAsset) URL() *url.URL              { return a.url }
+func (a *testAsset) MediaType() string          { return MediaTypeByURL(a.url) }
+func (a *testAsset) Data() []byte               { return nil }
+func (a *testAsset) References() []Reference    { return nil }
+func (a *testAsset) Embeds() []Embed            { return nil }
+func (a *testAsset) Merge(Asset, Relation) bool { return false }
+func (a *testAsset) Rewrite(to *url.URL)        { a.url = to }
+
+func (a *testBundle) URL() *url.URL           { return a.url }
+func (a *testBundle) MediaType() string       { return MediaTypeByURL(a.url) }
+func (a *testBundle) Data() []byte            { return a.data }
+func (a *testBundle) References() []Reference { return nil }
+func (a *testBundle) Embeds() []Embed         { return nil }
+func (a *testBundle) Rewrite(to *url.URL)     { a.url = to }
+
+func (a *testBundle) Merge(b Asset, r Relation) bool {
+	a.data = append(a.data, b.Data()...)
+	return true
+}
+
+func (r *testReference) VisitRelation(v RelationVisitor) { v.Reference(r) }
+func (r *testReference) URL() *url.URL                   { return r.url }
+func (r *testReference) Rewrite(to *url.URL)             { r.url = to }
+func (r *testReference) Flags() Flags                    { return nil }
+
+func TestGraphRewrite(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/css/app.css"}}
+	c := &testAsset{&url.URL{Path: "/css/base.css"}}
+
+	ab := &testReference{&url.URL{Path: "css/app.css"}}
+	bc := &testReference{&url.URL{Path: "base.css"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+	graph.Add(c)
+
+	graph.Relate(a, b, ab)
+	graph.Relate(b, c, bc)
+
+	assert.True(t, graph.Rewrite(b, &url.URL{Path: "/styles/app.css"}))
+
+	assert.Equal(t, &url.URL{Path: "/styles/app.css"}, b.URL())
+	assert.Equal(t, &url.URL{Path: "styles/app.css"}, ab.URL())
+	assert.Equal(t, &url.URL{Path: "../css/base.css"}, bc.URL())
+
+	assert.False(t, graph.Rewrite(&testAsset{}, &url.URL{}))
+}
+
+func TestGraphMove(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Scheme: "https", Host: "example.com", Path: "/css/app.css"}}
+	c := &testAsset{&url.URL{Scheme: "https", Host: "example.com", Path: "/fonts/foo.woff"}}
+
+	ab := &testReference{&url.URL{Scheme: "https", Host: "example.com", Path: "/css/app.css"}}
+	bc := &testReference{&url.URL{Path: "../fonts/foo.woff"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+	graph.Add(c)
+
+	graph.Relate(a, b, ab)
+	graph.Relate(b, c, bc)
+
+	graph.Move(map[Asset]*url.URL{
+		b: {Path: "/vendor/example.com/css/app.css"},
+		c: {Path: "/vendor/example.com/fonts/foo.woff"},
+	})
+
+	assert.Equal(t, &url.URL{Path: "/vendor/example.com/css/app.css"}, b.URL())
+	assert.Equal(t, &url.URL{Path: "/vendor/example.com/fonts/foo.woff"}, c.URL())
+	assert.Equal(t, &url.URL{Path: "vendor/example.com/css/app.css"}, ab.URL())
+	assert.Equal(t, &url.URL{Path: "../fonts/foo.woff"}, bc.URL())
+}
+
+func TestGraphPublish(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/css/app.css"}}
+	c := &testAsset{&url.URL{Path: "/css/bg.png"}}
+
+	ab := &testReference{&url.URL{Path: "css/app.css"}}
+	bc := &testReference{&url.URL{Path: "bg.png"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+	graph.Add(c)
+
+	graph.Relate(a, b, ab)
+	graph.Relate(b, c, bc)
+
+	assert.True(t, graph.Publish(b, &url.URL{Scheme: "https", Host: "cdn.com", Path: "/css/app.css"}))
+	assert.True(t, graph.Publish(c, &url.URL{Path: "/static/css/bg.png"}))
+
+	assert.Equal(t, &url.URL{Path: "/css/app.css"}, b.URL())
+	assert.Equal(t, &url.URL{Scheme: "https", Host: "cdn.com", Path: "/css/app.css"}, ab.URL())
+	assert.Equal(t, &url.URL{Path: "../static/css/bg.png"}, bc.URL())
+
+	assert.False(t, graph.Publish(&testAsset{}, &url.URL{}))
+}
+
+func TestGraphUnrelate(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/app.css"}}
+
+	ab := &testReference{&url.URL{Path: "app.css"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+
+	graph.Relate(a, b, ab)
+
+	assert.False(t, graph.Unrelate(b, a, ab))
+	assert.True(t, graph.Unrelate(a, b, ab))
+	assert.False(t, graph.Unrelate(a, b, ab))
+
+	outdegree, _ := graph.Outdegree(a)
+	indegree, _ := graph.Indegree(b)
+
+	assert.Equal(t, 0, outdegree)
+	assert.Equal(t, 0, indegree)
+}
+
+func TestGraphOrder(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/b.css"}}
+	c := &testAsset{&url.URL{Path: "/c.css"}}
+	d := &testAsset{&url.URL{Path: "/d.css"}}
+
+	ac := &testReference{&url.URL{Path: "c.css"}}
+	ab := &testReference{&url.URL{Path: "b.css"}}
+	ad := &testReference{&url.URL{Path: "d.css"}}
+
+	graph := NewGraph()
+
+	for i := 0; i < 16; i++ {
+		graph.Add(&testAsset{&url.URL{Path: "/other.css"}})
+	}
+
+	graph.Add(a)
+	graph.Add(d)
+	graph.Add(c)
+	graph.Add(b)
+
+	graph.Relate(a, c, ac)
+	graph.Relate(a, b, ab)
+	graph.Relate(a, d, ad)
+
+	assets := graph.Assets()
+
+	assert.Equal(t, []Asset{a, d, c, b}, assets[len(assets)-4:])
+
+	edges, _ := graph.Outgoing(a)
+
+	assert.Equal(t, []Edge{{ac, c}, {ab, b}, {ad, d}}, edges)
+
+	graph.Delete(b)
+	graph.Relate(a, b, ab)
+
+	edges, _ = graph.Outgoing(a)
+
+	assert.Equal(t, []Edge{{ac, c}, {ad, d}}, edges)
+
+	graph.Relate(a, c, ad)
+
+	edges, _ = graph.Outgoing(a)
+
+	assert.Equal(t, []Edge{{ac, c}, {ad, c}}, edges)
+
+	indegree, _ := graph.Indegree(d)
+
+	assert.Equal(t, 0, indegree)
+}
+
+func TestGraphIndex(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/app.css"}}
+	c := &testAsset{&url.URL{Path: "/index.html"}}
+	d := &testAsset{&url.URL{Path: "/base.css"}}
+
+	ab := &testReference{&url.URL{Path: "app.css"}}
+	ad := &testReference{&url.URL{Path: "base.css"}}
+	bd := &testReference{&url.URL{Path: "base.css"}}
+	bd2 := &testReference{&url.URL{Path: "base.css?v=2"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+	graph.Add(c)
+	graph.Add(d)
+
+	graph.Relate(a, b, ab)
+	graph.Relate(a, d, ad)
+	graph.Relate(b, d, bd)
+	graph.Relate(b, d, bd2)
+
+	found, ok := graph.ByURL(&url.URL{Path: "/index.html", Fragment: "top"})
+
+	assert.True(t, ok)
+	assert.Equal(t, a, found)
+
+	assert.Equal(t, []Asset{b, d}, graph.ByMediaType("text/css"))
+
+	relation, ok := graph.Relation(b, d)
+
+	assert.True(t, ok)
+	assert.Equal(t, bd, relation)
+	assert.Equal(t, []Relation{bd, bd2}, graph.Relations(b, d))
+
+	graph.Unrelate(b, d, bd)
+
+	assert.Equal(t, []Relation{bd2}, graph.Relations(b, d))
+
+	graph.Rewrite(d, &url.URL{Path: "/css/base.css"})
+
+	_, ok = graph.ByURL(&url.URL{Path: "/base.css"})
+	assert.False(t, ok)
+
+	found, _ = graph.ByURL(&url.URL{Path: "/css/base.css"})
+	assert.Equal(t, d, found)
+
+	graph.Delete(a)
+
+	found, _ = graph.ByURL(&url.URL{Path: "/index.html"})
+	assert.Equal(t, c, found)
+
+	_, ok = graph.Relation(a, b)
+	assert.False(t, ok)
+
+	assert.Equal(t, []Asset{b, c, d}, graph.Assets())
+
+	graph.Delete(b)
+	graph.Delete(c)
+
+	assert.Equal(t, []Asset{d}, graph.Assets())
+	assert.Equal(t, []Asset{d}, graph.ByMediaType("text/css"))
+	assert.Equal(t, []Asset{d}, graph.Roots())
+}
+
+// benchmarkGraph builds a graph of n stylesheets, each importing the next and
+// imported by a page.
+func benchmarkGraph(n int) (*Graph, []Asset) {
+	graph := NewGraph()
+
+	page := &testAsset{&url.URL{Path: "/index.html"}}
+
+	graph.Add(page)
+
+	assets := make([]Asset, n)
+
+	for i := range assets {
+		assets[i] = &testAsset{&url.URL{Path: fmt.Sprintf("/css/%d/%d.css", i%100, i)}}
+
+		graph.Add(assets[i])
+		graph.Relate(page, assets[i], &testReference{assets[i].URL()})
+
+		if i > 0 {
+			graph.Relate(assets[i-1], assets[i], &testReference{assets[i].URL()})
+		}
+	}
+
+	return graph, assets
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	a := &testAsset{&url.URL{Path: "/index.html"}}
+	b := &testAsset{&url.URL{Path: "/app.css"}}
+	c := &testAsset{&url.URL{Path: "/app.js"}}
+
+	graph := NewGraph()
+
+	graph.Add(a)
+	graph.Add(b)
+
+	graph.Relate(a, b, &testReference{&url.URL{Path: "app.css"}})
+
+	snapshot := graph.Snapshot()
+
+	graph.Move(map[Asset]*url.URL{b: {Path: "/css/app.css"}})
+	graph.Add(c)
+	graph.Relate(a, c, &testReference{&url.URL{Path: "app.js"}})
+	graph.Delete(b)
+
+	assert.Equal(t, []Asset{a, c}, graph.Assets())
+
+	assets := snapshot.Assets()
+
+	// The assets of the snapshot are copies that are not moved along with
+	// the assets they were copied from.
+	if assert.Len(t, assets, 2) {
+		assert.Equal(t, "/index.html", assets[0].URL().Path)
+		assert.Equal(t, "/app.css", assets[1].URL().Path)
+	}
+
+	edges, _ := snapshot.Outgoing(assets[0])
+
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, assets[1], edges[0].Asset)
+		assert.Equal(t, "app.css", edges[0].Relation.(Reference).URL().Path)
+		assert.Equal(t, []Reference{edges[0].Relation.(Reference)}, assets[0].References())
+	}
+
+	found, ok := snapshot.ByURL(&url.URL{Path: "/app.css"})
+
+	assert.True(t, ok)
+	assert.Equal(t, assets[1], found)
+
+	_, ok = snapshot.ByURL(&url.URL{Path: "/app.js"})
+
+	assert.False(t, ok)
+	assert.Equal(t, []Asset{assets[1]}, snapshot.ByMediaType("text/css"))
+
+	snapshot.Delete(assets[0])
+
+	assert.True(t, graph.Has(a))
+}
+
+func TestGraphConcurrent(t *testing.T) {
+	graph, assets := benchmarkGraph(100)
+
+	page, _ := graph.ByURL(&url.URL{Path: "/index.html"})
+
+	bundle := &testBundle{url: &url.URL{Path: "/bundle.css"}}
+
+	graph.Add(bundle)
+
+	var wait sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wait.Add(3)
+
+		go func(i int) {
+			defer wait.Done()
+
+			for j := i; j < len(assets); j += 4 {
+				graph.Delete(assets[j])
+
+				asset := &testAsset{&url.URL{Path: fmt.Sprintf("/js/%d.js", j)}}
+
+				graph.Add(asset)
+				graph.Relate(page, asset, &testReference{asset.URL()})
+			}
+		}(i)
+
+		go func(i int) {
+			defer wait.Done()
+
+			for j := 0; j < 25; j++ {
+				part := &testBundle{url: &url.URL{Path: fmt.Sprintf("/css/part/%d/%d.css", i, j)}, data: []byte("a{}")}
+
+				graph.Add(part)
+				graph.Relate(bundle, part, &testReference{part.URL()})
+				graph.Merge(bundle, part)
+
+				graph.Move(map[Asset]*url.URL{bundle: {Path: fmt.Sprintf("/bundle.%d.%d.css", i, j)}})
+			}
+		}(i)
+
+		go func() {
+			defer wait.Done()
+
+			for j := 0; j < 50; j++ {
+				snapshot := graph.Snapshot()
+
+				for _, asset := range snapshot.Assets() {
+					_ = asset.URL().String()
+					asset.Data()
+
+					snapshot.Outgoing(asset)
+				}
+
+				graph.ByURL(assets[j].URL())
+				graph.ByMediaType("text/css")
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	outdegree, _ := graph.Outdegree(page)
+
+	assert.Equal(t, len(assets)+2, graph.Size())
+	assert.Equal(t, len(assets), outdegree)
+	assert.Equal(t, []Asset{bundle}, graph.ByMediaType("text/css"))
+	assert.Len(t, bundle.Data(), 4*25*3)
+}
+
+func BenchmarkGraphAdd(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkGraph(10000)
+	}
+}
+
+func BenchmarkGraphLookup(b *testing.B) {
+	graph, assets := benchmarkGraph(10000)
+
+	b.ResetTimer()
+
+	b.Run("Query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			graph.Lookup(ByURL(assets[i%len(assets)].URL()))
+		}
+	})
+
+	b.Run("Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			graph.ByURL(assets[i%len(assets)].URL())
+		}
+	})
+}
+
+func BenchmarkGraphRelation(b *testing.B) {
+	graph, assets := benchmarkGraph(10000)
+
+	page, _ := graph.ByURL(&url.URL{Path: "/index.html"})
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		graph.Relation(page, assets[i%len(assets)])
+	}
+}
+
+func BenchmarkGraphDelete(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		graph, assets := benchmarkGraph(10000)
+		b.StartTimer()
+
+		for _, asset := range assets {
+			graph.Delete(asset)
+		}
+	}
+}
@@ -8,18 +8,25 @@ import (
 	"github.com/kasperisager/pak/pkg/asset/html/ast"
 	"github.com/kasperisager/pak/pkg/asset/html/parser"
 	"github.com/kasperisager/pak/pkg/asset/html/scanner"
+	"github.com/kasperisager/pak/pkg/asset/html/token"
 	"github.com/kasperisager/pak/pkg/asset/html/writer"
+	"github.com/kasperisager/pak/pkg/diagnostic"
 )
 
 type (
 	Asset struct {
 		url      *url.URL
 		Document *ast.Document
+
+		// The assets merged into the elements of the document, whose contents
+		// may change after being merged.
+		merged map[*ast.Element]asset.Asset
 	}
 
 	Reference struct {
 		url         *url.URL
 		flags       asset.Flags
+		Element     *ast.Element
 		Attribute   *ast.Attribute
 		Conditional bool
 	}
@@ -40,12 +47,30 @@ func From(url *url.URL, data []byte) (*Asset, error) {
 	tokens, err := scanner.Scan(runes)
 
 	if err != nil {
+		switch err := err.(type) {
+		case scanner.SyntaxError:
+			return nil, diagnostic.From(url, runes, err.Offset, err.Message)
+		}
+
 		return nil, err
 	}
 
 	document, err := parser.Parse(tokens)
 
 	if err != nil {
+		switch err := err.(type) {
+		case parser.SyntaxError:
+			// The offset of a parser error is the index of the offending token
+			// rather than an offset into the source.
+			offset := len(runes)
+
+			if err.Offset < len(tokens) {
+				offset = token.Offset(tokens[err.Offset])
+			}
+
+			return nil, diagnostic.From(url, runes, offset, err.Message)
+		}
+
 		return nil, err
 	}
 
@@ -71,15 +96,40 @@ func (a *Asset) Embeds() []asset.Embed {
 	return collectEmbeds(a.url, a.Document.Root, nil)
 }
 
+// Data renders the document with the current contents of the assets merged
+// into it. The document itself is left as it is, so pages can be rendered by
+// several goroutines at once.
 func (a *Asset) Data() []byte {
+	contents := make(map[*ast.Element]string, len(a.merged))
+
+	for element, merged := range a.merged {
+		contents[element] = string(merged.Data())
+	}
+
 	var b bytes.Buffer
-	writer.Write(&b, a.Document)
+	writer.WriteReplacing(&b, a.Document, contents)
 	return b.Bytes()
 }
 
+// Text returns the text of an element of the document, which is the current
+// contents of the asset merged into it if there is one.
+func (a *Asset) Text(element *ast.Element) string {
+	if merged, ok := a.merged[element]; ok {
+		return string(merged.Data())
+	}
+
+	return element.Text()
+}
+
 func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	switch r := r.(type) {
 	case *Embed:
+		if a.merged == nil {
+			a.merged = make(map[*ast.Element]asset.Asset)
+		}
+
+		a.merged[r.Element] = b
+
 		r.Element.Children = []ast.Node{
 			&ast.Text{
 				Data: string(b.Data()),
@@ -92,6 +142,19 @@ func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	return false
 }
 
+func (a *Asset) Rewrite(to *url.URL) {
+	a.url = to
+}
+
+// Clone returns a copy of the asset with its own copy of the document. The
+// copy does not follow changes to the assets merged into the original.
+func (a *Asset) Clone() asset.Asset {
+	return &Asset{
+		url:      a.url,
+		Document: &ast.Document{Root: cloneElement(a.Document.Root)},
+	}
+}
+
 func (r *Reference) VisitRelation(v asset.RelationVisitor) {
 	v.Reference(r)
 }
@@ -102,12 +165,74 @@ func (r *Reference) URL() *url.URL {
 
 func (r *Reference) Rewrite(to *url.URL) {
 	r.url = to
+	r.Attribute.Value = to.String()
 }
 
 func (r *Reference) Flags() asset.Flags {
 	return r.flags
 }
 
+// Inline replaces the element of a stylesheet or script reference with an
+// inline style or script, returning the embed that the contents of the
+// referenced asset must be merged into. Classic scripts that are deferred or
+// run asynchronously are not inlined as that would change when they run.
+func (r *Reference) Inline() (*Embed, bool) {
+	element := r.Element
+
+	if element == nil {
+		return nil, false
+	}
+
+	switch element.Name {
+	case "link":
+		rel := element.Attribute("rel")
+
+		if rel == nil || rel.Value != "stylesheet" {
+			break
+		}
+
+		media := element.Attribute("media")
+
+		element.Name = "style"
+		element.Attributes = nil
+
+		if media != nil {
+			element.Attributes = append(element.Attributes, media)
+		}
+
+		return &Embed{
+			mediaType: "text/css",
+			flags:     r.flags,
+			Element:   element,
+		}, true
+
+	case "script":
+		typ := element.Attribute("type")
+
+		if typ != nil && !javascript(typ.Value) {
+			break
+		}
+
+		module := typ != nil && typ.Value == "module"
+
+		if !module && (element.Attribute("defer") != nil || element.Attribute("async") != nil) {
+			break
+		}
+
+		for _, name := range []string{"src", "integrity", "crossorigin"} {
+			element.RemoveAttribute(name)
+		}
+
+		return &Embed{
+			mediaType: "application/javascript",
+			flags:     r.flags,
+			Element:   element,
+		}, true
+	}
+
+	return nil, false
+}
+
 func (e *Embed) VisitRelation(v asset.RelationVisitor) {
 	v.Embed(e)
 }
@@ -124,6 +249,49 @@ func (e *Embed) Flags() asset.Flags {
 	return e.flags
 }
 
+// Extract replaces the element of a style or script embed with one that loads
+// the given URL instead, returning the reference to the URL. Import maps can
+// not be loaded from a URL and so are not extracted.
+func (e *Embed) Extract(to *url.URL) (*Reference, bool) {
+	element := e.Element
+
+	switch e.mediaType {
+	case "text/css":
+		media := element.Attribute("media")
+
+		element.Name = "link"
+		element.Attributes = []*ast.Attribute{{Name: "rel", Value: "stylesheet"}}
+
+		if media != nil {
+			element.Attributes = append(element.Attributes, media)
+		}
+
+		element.SetAttribute("href", to.String())
+		element.Children = nil
+
+		return &Reference{
+			url:         to,
+			flags:       e.flags,
+			Element:     element,
+			Attribute:   element.Attribute("href"),
+			Conditional: media != nil && media.Value != "" && media.Value != "all",
+		}, true
+
+	case "application/javascript":
+		element.SetAttribute("src", to.String())
+		element.Children = nil
+
+		return &Reference{
+			url:       to,
+			flags:     e.flags,
+			Element:   element,
+			Attribute: element.Attribute("src"),
+		}, true
+	}
+
+	return nil, false
+}
+
 func collectReferences(
 	base *url.URL,
 	element *ast.Element,
@@ -139,7 +307,7 @@ func collectReferences(
 			break
 		}
 
-		url, err := base.Parse(href.Value)
+		url, err := url.Parse(href.Value)
 
 		if err != nil {
 			break
@@ -166,6 +334,7 @@ func collectReferences(
 			references = append(references, &Reference{
 				url:         url,
 				flags:       flags,
+				Element:     element,
 				Attribute:   href,
 				Conditional: conditional,
 			})
@@ -179,6 +348,7 @@ func collectReferences(
 			references = append(references, &Reference{
 				url:         url,
 				flags:       flags,
+				Element:     element,
 				Attribute:   href,
 				Conditional: conditional,
 			})
@@ -199,22 +369,20 @@ func collectReferences(
 
 		typ := element.Attribute("type")
 
-		if typ == nil {
-			break
-		}
-
-		switch typ.Value {
-		case "importmap":
+		switch {
+		case typ != nil && typ.Value == "importmap":
 			references = append(references, &Reference{
 				url:       url,
 				flags:     flags.Set("mediaType", "application/importmap+json"),
+				Element:   element,
 				Attribute: src,
 			})
 
 		default:
 			references = append(references, &Reference{
 				url:       url,
-				flags:     flags.Set("module", typ.Value == "module"),
+				flags:     flags.Set("module", typ != nil && typ.Value == "module"),
+				Element:   element,
 				Attribute: src,
 			})
 		}
@@ -260,6 +428,10 @@ func collectEmbeds(
 				Element:   element,
 			})
 
+		// Scripts of other types, such as JSON-LD, are data blocks that are
+		// left as they are.
+		case typ != nil && !javascript(typ.Value):
+
 		default:
 			var flags asset.Flags
 
@@ -269,6 +441,7 @@ func collectEmbeds(
 				mediaType: "application/javascript",
 				data:      []byte(element.Text()),
 				flags:     flags,
+				Element:   element,
 			})
 		}
 	}
@@ -282,3 +455,39 @@ func collectEmbeds(
 
 	return embeds
 }
+
+func javascript(typ string) bool {
+	switch typ {
+	case "", "module", "text/javascript", "application/javascript":
+		return true
+	}
+
+	return false
+}
+
+func cloneElement(element *ast.Element) *ast.Element {
+	clone := &ast.Element{
+		Name:       element.Name,
+		Attributes: make([]*ast.Attribute, len(element.Attributes)),
+		Children:   make([]ast.Node, len(element.Children)),
+	}
+
+	for i, attribute := range element.Attributes {
+		clone.Attributes[i] = &ast.Attribute{Name: attribute.Name, Value: attribute.Value}
+	}
+
+	for i, child := range element.Children {
+		switch child := child.(type) {
+		case *ast.Element:
+			clone.Children[i] = cloneElement(child)
+
+		case *ast.Text:
+			clone.Children[i] = &ast.Text{Data: child.Data}
+
+		default:
+			clone.Children[i] = child
+		}
+	}
+
+	return clone
+}
@@ -0,0 +1,86 @@
+package html
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kasperisager/pak/pkg/asset"
+	"github.com/kasperisager/pak/pkg/asset/css"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	page, err := From(&url.URL{Path: "/index.html"}, []byte(
+		`<!doctype html><html><head><style>a{background:url(a.png)}</style></head><body></body></html>`,
+	))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	embed := page.Embeds()[0]
+
+	style, err := css.From(page.URL(), embed.Data())
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.True(t, page.Merge(style, embed))
+
+	// The style may change after being merged, such as when the files that
+	// it references are renamed, which the page must reflect.
+	style.References()[0].Rewrite(&url.URL{Path: "a.1234.png"})
+
+	assert.Equal(t,
+		`<!doctype html><html><head><style>a{background:url(a.1234.png)}</style></head><body></body></html>`,
+		string(page.Data()),
+	)
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	page, err := From(&url.URL{Path: "/index.html"}, []byte(
+		`<!doctype html><html><head><style>a{background:url(a.png)}</style></head><body></body></html>`,
+	))
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	embed := page.Embeds()[0]
+
+	style, err := css.From(page.URL(), embed.Data())
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	graph := asset.NewGraph()
+
+	graph.Add(page)
+	graph.Add(style)
+	graph.Relate(page, style, embed)
+
+	assert.True(t, graph.Merge(page, style))
+
+	var wait sync.WaitGroup
+
+	// Rendering a page with merged contents must not change the page, so
+	// several snapshots can render it at once.
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for j := 0; j < 10; j++ {
+				for _, asset := range graph.Snapshot().Assets() {
+					assert.Contains(t, string(asset.Data()), `<style>a{background:url(a.png)}</style>`)
+				}
+			}
+		}()
+	}
+
+	wait.Wait()
+}
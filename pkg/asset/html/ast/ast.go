@@ -45,6 +45,23 @@ func (e *Element) Attribute(name string) *Attribute {
 	return nil
 }
 
+func (e *Element) SetAttribute(name string, value string) {
+	if attribute := e.Attribute(name); attribute != nil {
+		attribute.Value = value
+	} else {
+		e.Attributes = append(e.Attributes, &Attribute{Name: name, Value: value})
+	}
+}
+
+func (e *Element) RemoveAttribute(name string) {
+	for i, attribute := range e.Attributes {
+		if name == attribute.Name {
+			e.Attributes = append(e.Attributes[:i:i], e.Attributes[i+1:]...)
+			return
+		}
+	}
+}
+
 func (e *Element) Text() string {
 	var text string
 
@@ -0,0 +1,37 @@
+package html
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/url"
+
+	"github.com/kasperisager/pak/pkg/asset/html/ast"
+)
+
+func init() {
+	gob.RegisterName("html/ast.Element", &ast.Element{})
+	gob.RegisterName("html/ast.Text", &ast.Text{})
+}
+
+func FromBinary(url *url.URL, data []byte) (*Asset, error) {
+	var document ast.Document
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return &Asset{
+		url:      url,
+		Document: &document,
+	}, nil
+}
+
+func (a *Asset) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := gob.NewEncoder(&b).Encode(a.Document); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,31 @@
+package html
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinary(t *testing.T) {
+	var tests = []string{
+		`<!doctype html><html><head><title>Foo</title></head><body></body></html>`,
+		`<!doctype html><html><head><link rel="stylesheet" href="foo.css"><style>a{b:c}</style></head><body><p class="foo">Bar<br>baz</p></body></html>`,
+	}
+
+	for _, test := range tests {
+		url := &url.URL{Path: "/index.html"}
+
+		asset, err := From(url, []byte(test))
+		assert.Nil(t, err, test)
+
+		data, err := asset.MarshalBinary()
+		assert.Nil(t, err, test)
+
+		decoded, err := FromBinary(url, data)
+		assert.Nil(t, err, test)
+
+		assert.Equal(t, asset.Document, decoded.Document, test)
+		assert.Equal(t, asset.Data(), decoded.Data(), test)
+	}
+}
@@ -47,7 +47,7 @@ func parseDocument(offset int, tokens []token.Token) (int, []token.Token, *ast.D
 		}
 	}
 
-	offset, tokens, documentElement, err := parseDocumentElement(offset+1, tokens[1:])
+	offset, tokens, documentElement, err := parseDocumentElement(offset, tokens)
 
 	if err != nil {
 		return offset, tokens, nil, err
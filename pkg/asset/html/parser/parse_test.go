@@ -43,6 +43,23 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			// The token that follows the doctype must not be skipped when there
+			// is no whitespace between the two.
+			`<!doctype html><html class="foo"></html>`,
+			&ast.Document{
+				Root: &ast.Element{
+					Name: "html",
+					Attributes: []*ast.Attribute{
+						{Name: "class", Value: "foo"},
+					},
+					Children: []ast.Node{
+						&ast.Element{Name: "head"},
+						&ast.Element{Name: "body"},
+					},
+				},
+			},
+		},
 		{
 			`
 			<!doctype html>
@@ -47,3 +47,18 @@ func (t StartTag) VisitToken(v TokenVisitor) { v.StartTag(t) }
 func (t EndTag) VisitToken(v TokenVisitor) { v.EndTag(t) }
 
 func (t Character) VisitToken(v TokenVisitor) { v.Character(t) }
+
+func Offset(token Token) int {
+	switch t := token.(type) {
+	case DocumentType:
+		return t.Offset
+	case StartTag:
+		return t.Offset
+	case EndTag:
+		return t.Offset
+	case Character:
+		return t.Offset
+	}
+
+	return -1
+}
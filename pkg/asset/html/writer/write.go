@@ -8,15 +8,21 @@ import (
 )
 
 func Write(w io.Writer, document *ast.Document) {
-	writeDocument(w, document)
+	writeDocument(w, document, nil)
 }
 
-func writeDocument(w io.Writer, document *ast.Document) {
+// WriteReplacing writes a document with the contents of the given elements
+// replaced by the given text, leaving the document itself unchanged.
+func WriteReplacing(w io.Writer, document *ast.Document, contents map[*ast.Element]string) {
+	writeDocument(w, document, contents)
+}
+
+func writeDocument(w io.Writer, document *ast.Document, contents map[*ast.Element]string) {
 	fmt.Fprintf(w, "<!doctype html>")
-	writeElement(w, document.Root)
+	writeElement(w, document.Root, contents)
 }
 
-func writeElement(w io.Writer, element *ast.Element) {
+func writeElement(w io.Writer, element *ast.Element, contents map[*ast.Element]string) {
 	fmt.Fprintf(w, "<%s", element.Name)
 
 	for _, attribute := range element.Attributes {
@@ -31,10 +37,16 @@ func writeElement(w io.Writer, element *ast.Element) {
 		return
 	}
 
+	if text, ok := contents[element]; ok {
+		fmt.Fprintf(w, "%s", text)
+		fmt.Fprintf(w, "</%s>", element.Name)
+		return
+	}
+
 	for _, child := range element.Children {
 		switch child := child.(type) {
 		case *ast.Element:
-			writeElement(w, child)
+			writeElement(w, child, contents)
 
 		case *ast.Text:
 			writeText(w, child)
@@ -45,7 +57,7 @@ func writeElement(w io.Writer, element *ast.Element) {
 }
 
 func writeAttribute(w io.Writer, attribute *ast.Attribute) {
-	fmt.Fprintf(w, attribute.Name)
+	fmt.Fprintf(w, "%s", attribute.Name)
 
 	if attribute.Value != "" {
 		fmt.Fprintf(w, `="%s"`, attribute.Value)
@@ -53,5 +65,5 @@ func writeAttribute(w io.Writer, attribute *ast.Attribute) {
 }
 
 func writeText(w io.Writer, text *ast.Text) {
-	fmt.Fprintf(w, text.Data)
+	fmt.Fprintf(w, "%s", text.Data)
 }
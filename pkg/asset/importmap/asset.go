@@ -62,6 +62,17 @@ func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	return false
 }
 
+func (a *Asset) Rewrite(to *url.URL) {
+	a.url = to
+}
+
+// Clone returns a copy of the asset that shares its import map, which is
+// never changed once parsed.
+func (a *Asset) Clone() asset.Asset {
+	clone := *a
+	return &clone
+}
+
 func (r *Reference) URL() *url.URL {
 	return r.url
 }
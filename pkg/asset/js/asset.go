@@ -3,11 +3,14 @@ package js
 import (
 	"bytes"
 	"net/url"
+	"strings"
 
 	"github.com/kasperisager/pak/pkg/asset"
 	"github.com/kasperisager/pak/pkg/asset/js/ast"
 	"github.com/kasperisager/pak/pkg/asset/js/parser"
+	"github.com/kasperisager/pak/pkg/asset/js/scanner"
 	"github.com/kasperisager/pak/pkg/asset/js/writer"
+	"github.com/kasperisager/pak/pkg/diagnostic"
 )
 
 type (
@@ -25,9 +28,19 @@ type (
 const MediaType = "application/javascript"
 
 func From(url *url.URL, data []byte, flags asset.Flags) (*Asset, error) {
-	program, err := parser.Parse(bytes.Runes(data), ast.Module)
+	runes := bytes.Runes(data)
+
+	program, err := parser.Parse(runes, ast.Module)
 
 	if err != nil {
+		switch err := err.(type) {
+		case parser.SyntaxError:
+			return nil, diagnostic.From(url, runes, err.Offset, err.Message)
+
+		case scanner.SyntaxError:
+			return nil, diagnostic.From(url, runes, err.Offset, err.Message)
+		}
+
 		return nil, err
 	}
 
@@ -60,6 +73,33 @@ func (a *Asset) Merge(b asset.Asset, r asset.Relation) bool {
 	return false
 }
 
+func (a *Asset) Rewrite(to *url.URL) {
+	a.url = to
+}
+
+// Clone returns a copy of the asset with its own copy of the import
+// declarations, which are the only parts of the program that are rewritten.
+func (a *Asset) Clone() asset.Asset {
+	program := *a.Program
+
+	program.Body = make([]ast.ProgramBody, len(a.Program.Body))
+
+	for i, statement := range a.Program.Body {
+		switch statement := statement.(type) {
+		case *ast.ImportDeclaration:
+			declaration := *statement
+			source := *statement.Source
+			declaration.Source = &source
+			program.Body[i] = &declaration
+
+		default:
+			program.Body[i] = statement
+		}
+	}
+
+	return &Asset{a.url, &program}
+}
+
 func (r *Reference) VisitRelation(v asset.RelationVisitor) {
 	v.Reference(r)
 }
@@ -69,6 +109,15 @@ func (r *Reference) URL() *url.URL {
 }
 
 func (r *Reference) Rewrite(to *url.URL) {
+	r.url = to
+
+	specifier := to.String()
+
+	if !to.IsAbs() && !strings.HasPrefix(specifier, "/") && !strings.HasPrefix(specifier, ".") {
+		specifier = "./" + specifier
+	}
+
+	r.Declaration.Source.Value = specifier
 }
 
 func (r *Reference) Flags() asset.Flags {
@@ -0,0 +1,105 @@
+package js
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/url"
+
+	"github.com/kasperisager/pak/pkg/asset/js/ast"
+)
+
+func init() {
+	gob.RegisterName("js/ast.Identifier", &ast.Identifier{})
+	gob.RegisterName("js/ast.StringLiteral", &ast.StringLiteral{})
+	gob.RegisterName("js/ast.BooleanLiteral", &ast.BooleanLiteral{})
+	gob.RegisterName("js/ast.NullLiteral", &ast.NullLiteral{})
+	gob.RegisterName("js/ast.NumberLiteral", &ast.NumberLiteral{})
+	gob.RegisterName("js/ast.RegExpLiteral", &ast.RegExpLiteral{})
+	gob.RegisterName("js/ast.ExpressionStatement", &ast.ExpressionStatement{})
+	gob.RegisterName("js/ast.BlockStatement", &ast.BlockStatement{})
+	gob.RegisterName("js/ast.EmptyStatement", &ast.EmptyStatement{})
+	gob.RegisterName("js/ast.DebuggerStatement", &ast.DebuggerStatement{})
+	gob.RegisterName("js/ast.WithStatement", &ast.WithStatement{})
+	gob.RegisterName("js/ast.ReturnStatement", &ast.ReturnStatement{})
+	gob.RegisterName("js/ast.LabeledStatement", &ast.LabeledStatement{})
+	gob.RegisterName("js/ast.BreakStatement", &ast.BreakStatement{})
+	gob.RegisterName("js/ast.ContinueStatement", &ast.ContinueStatement{})
+	gob.RegisterName("js/ast.IfStatement", &ast.IfStatement{})
+	gob.RegisterName("js/ast.SwitchStatement", &ast.SwitchStatement{})
+	gob.RegisterName("js/ast.SwitchCase", &ast.SwitchCase{})
+	gob.RegisterName("js/ast.ThrowStatement", &ast.ThrowStatement{})
+	gob.RegisterName("js/ast.TryStatement", &ast.TryStatement{})
+	gob.RegisterName("js/ast.CatchClause", &ast.CatchClause{})
+	gob.RegisterName("js/ast.WhileStatement", &ast.WhileStatement{})
+	gob.RegisterName("js/ast.DoWhileStatement", &ast.DoWhileStatement{})
+	gob.RegisterName("js/ast.ForStatement", &ast.ForStatement{})
+	gob.RegisterName("js/ast.ForInStatement", &ast.ForInStatement{})
+	gob.RegisterName("js/ast.ForOfStatement", &ast.ForOfStatement{})
+	gob.RegisterName("js/ast.FunctionDeclaration", &ast.FunctionDeclaration{})
+	gob.RegisterName("js/ast.VariableDeclaration", &ast.VariableDeclaration{})
+	gob.RegisterName("js/ast.VariableDeclarator", &ast.VariableDeclarator{})
+	gob.RegisterName("js/ast.ThisExpression", &ast.ThisExpression{})
+	gob.RegisterName("js/ast.ArrayExpression", &ast.ArrayExpression{})
+	gob.RegisterName("js/ast.ObjectExpression", &ast.ObjectExpression{})
+	gob.RegisterName("js/ast.Property", &ast.Property{})
+	gob.RegisterName("js/ast.FunctionExpression", &ast.FunctionExpression{})
+	gob.RegisterName("js/ast.UnaryExpression", &ast.UnaryExpression{})
+	gob.RegisterName("js/ast.UpdateExpression", &ast.UpdateExpression{})
+	gob.RegisterName("js/ast.BinaryExpression", &ast.BinaryExpression{})
+	gob.RegisterName("js/ast.AssignmentExpression", &ast.AssignmentExpression{})
+	gob.RegisterName("js/ast.LogicalExpression", &ast.LogicalExpression{})
+	gob.RegisterName("js/ast.MemberExpression", &ast.MemberExpression{})
+	gob.RegisterName("js/ast.ConditionalExpression", &ast.ConditionalExpression{})
+	gob.RegisterName("js/ast.CallExpression", &ast.CallExpression{})
+	gob.RegisterName("js/ast.NewExpression", &ast.NewExpression{})
+	gob.RegisterName("js/ast.SequenceExpression", &ast.SequenceExpression{})
+	gob.RegisterName("js/ast.ArrowFunctionExpression", &ast.ArrowFunctionExpression{})
+	gob.RegisterName("js/ast.YieldExpression", &ast.YieldExpression{})
+	gob.RegisterName("js/ast.AwaitExpression", &ast.AwaitExpression{})
+	gob.RegisterName("js/ast.TemplateLiteral", &ast.TemplateLiteral{})
+	gob.RegisterName("js/ast.TaggedTemplateExpression", &ast.TaggedTemplateExpression{})
+	gob.RegisterName("js/ast.TemplateElement", &ast.TemplateElement{})
+	gob.RegisterName("js/ast.ObjectPattern", &ast.ObjectPattern{})
+	gob.RegisterName("js/ast.AssignmentProperty", &ast.AssignmentProperty{})
+	gob.RegisterName("js/ast.ArrayPattern", &ast.ArrayPattern{})
+	gob.RegisterName("js/ast.RestElement", &ast.RestElement{})
+	gob.RegisterName("js/ast.AssignmentPattern", &ast.AssignmentPattern{})
+	gob.RegisterName("js/ast.Super", &ast.Super{})
+	gob.RegisterName("js/ast.SpreadElement", &ast.SpreadElement{})
+	gob.RegisterName("js/ast.Class", &ast.Class{})
+	gob.RegisterName("js/ast.ClassBody", &ast.ClassBody{})
+	gob.RegisterName("js/ast.MethodDefinition", &ast.MethodDefinition{})
+	gob.RegisterName("js/ast.ClassDeclaration", &ast.ClassDeclaration{})
+	gob.RegisterName("js/ast.ClassExpression", &ast.ClassExpression{})
+	gob.RegisterName("js/ast.MetaProperty", &ast.MetaProperty{})
+	gob.RegisterName("js/ast.ImportDeclaration", &ast.ImportDeclaration{})
+	gob.RegisterName("js/ast.ImportSpecifier", &ast.ImportSpecifier{})
+	gob.RegisterName("js/ast.ImportDefaultSpecifier", &ast.ImportDefaultSpecifier{})
+	gob.RegisterName("js/ast.ImportNamespaceSpecifier", &ast.ImportNamespaceSpecifier{})
+	gob.RegisterName("js/ast.ExportNamedDeclaration", &ast.ExportNamedDeclaration{})
+	gob.RegisterName("js/ast.ExportSpecifier", &ast.ExportSpecifier{})
+	gob.RegisterName("js/ast.ExportDefaultDeclaration", &ast.ExportDefaultDeclaration{})
+	gob.RegisterName("js/ast.AnonymousDefaultExportedFunctionDeclaration", &ast.AnonymousDefaultExportedFunctionDeclaration{})
+	gob.RegisterName("js/ast.AnonymousDefaultExportedClassDeclaration", &ast.AnonymousDefaultExportedClassDeclaration{})
+	gob.RegisterName("js/ast.ExportAllDeclaration", &ast.ExportAllDeclaration{})
+}
+
+func FromBinary(url *url.URL, data []byte) (*Asset, error) {
+	var program ast.Program
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&program); err != nil {
+		return nil, err
+	}
+
+	return &Asset{url, &program}, nil
+}
+
+func (a *Asset) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := gob.NewEncoder(&b).Encode(a.Program); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
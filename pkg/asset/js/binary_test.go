@@ -0,0 +1,49 @@
+package js
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinary(t *testing.T) {
+	// The parser only covers part of the language, so the tests stick to
+	// the parts that it covers.
+	var tests = []string{
+		`import "foo"`,
+		`foo = "bar"`,
+		`foo *= "bar"`,
+		`foo, bar`,
+		`foo ? bar : baz`,
+		`foo || bar && baz`,
+		`foo === bar + baz`,
+		`!foo`,
+		`++foo`,
+		`foo--`,
+	}
+
+	for _, test := range tests {
+		url := &url.URL{Path: "/foo.js"}
+
+		asset, err := From(url, []byte(test), nil)
+
+		if !assert.Nil(t, err, test) {
+			continue
+		}
+
+		assert.NotEmpty(t, asset.Program.Body, test)
+
+		data, err := asset.MarshalBinary()
+		assert.Nil(t, err, test)
+
+		decoded, err := FromBinary(url, data)
+
+		if !assert.Nil(t, err, test) {
+			continue
+		}
+
+		assert.Equal(t, asset.Program, decoded.Program, test)
+		assert.Equal(t, asset.Data(), decoded.Data(), test)
+	}
+}
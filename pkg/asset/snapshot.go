@@ -0,0 +1,99 @@
+package asset
+
+import (
+	"net/url"
+)
+
+type (
+	// A frozenAsset is a copy of the state of an asset at the time a snapshot
+	// was taken. It shares nothing with the asset it was copied from, so it is
+	// unaffected by later changes to that asset.
+	frozenAsset struct {
+		url        *url.URL
+		mediaType  string
+		data       []byte
+		references []Reference
+		embeds     []Embed
+	}
+
+	frozenReference struct {
+		url   *url.URL
+		flags Flags
+	}
+
+	frozenEmbed struct {
+		mediaType string
+		data      []byte
+		flags     Flags
+	}
+
+	// A frozenRelation is a copy of a relation that is neither a reference
+	// nor an embed.
+	frozenRelation struct{}
+)
+
+func freezeAsset(asset Asset) *frozenAsset {
+	return &frozenAsset{
+		url:       cloneURL(asset.URL()),
+		mediaType: asset.MediaType(),
+		data:      append([]byte{}, asset.Data()...),
+	}
+}
+
+func freezeRelation(relation Relation) Relation {
+	var frozen Relation = &frozenRelation{}
+
+	relation.VisitRelation(RelationVisitor{
+		Reference: func(reference Reference) {
+			frozen = &frozenReference{
+				url:   cloneURL(reference.URL()),
+				flags: cloneFlags(reference.Flags()),
+			}
+		},
+
+		Embed: func(embed Embed) {
+			frozen = &frozenEmbed{
+				mediaType: embed.MediaType(),
+				data:      append([]byte{}, embed.Data()...),
+				flags:     cloneFlags(embed.Flags()),
+			}
+		},
+	})
+
+	return frozen
+}
+
+func (a *frozenAsset) URL() *url.URL              { return a.url }
+func (a *frozenAsset) MediaType() string          { return a.mediaType }
+func (a *frozenAsset) Data() []byte               { return a.data }
+func (a *frozenAsset) References() []Reference    { return a.references }
+func (a *frozenAsset) Embeds() []Embed            { return a.embeds }
+func (a *frozenAsset) Merge(Asset, Relation) bool { return false }
+func (a *frozenAsset) Rewrite(to *url.URL)        { a.url = to }
+
+func (r *frozenReference) VisitRelation(v RelationVisitor) { v.Reference(r) }
+func (r *frozenReference) URL() *url.URL                   { return r.url }
+func (r *frozenReference) Rewrite(to *url.URL)             { r.url = to }
+func (r *frozenReference) Flags() Flags                    { return r.flags }
+
+func (e *frozenEmbed) VisitRelation(v RelationVisitor) { v.Embed(e) }
+func (e *frozenEmbed) MediaType() string               { return e.mediaType }
+func (e *frozenEmbed) Data() []byte                    { return e.data }
+func (e *frozenEmbed) Flags() Flags                    { return e.flags }
+
+func (r *frozenRelation) VisitRelation(v RelationVisitor) {}
+
+func cloneURL(u *url.URL) *url.URL {
+	clone := *u
+	return &clone
+}
+
+func cloneFlags(flags Flags) Flags {
+	var clone Flags
+
+	for _, flag := range flags {
+		clone = clone.Set(flag.key, flag.value)
+	}
+
+	return clone
+}
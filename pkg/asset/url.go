@@ -15,7 +15,7 @@ func rebase(target *url.URL, from *url.URL, to *url.URL) *url.URL {
 
 	if from.Scheme == to.Scheme && from.Host == to.Host {
 		if path.IsAbs(target.Path) {
-			return &url.URL{Path: from.Path}
+			return suffix(&url.URL{Path: from.Path}, target)
 		}
 
 		path, _ := filepath.Rel(
@@ -23,16 +23,26 @@ func rebase(target *url.URL, from *url.URL, to *url.URL) *url.URL {
 			filepath.FromSlash(from.Path),
 		)
 
-		return &url.URL{Path: filepath.ToSlash(path)}
+		return suffix(&url.URL{Path: filepath.ToSlash(path)}, target)
 	}
 
 	return from
 }
 
 func rewrite(base *url.URL, from *url.URL, to *url.URL) *url.URL {
-	if from.Scheme == to.Scheme && from.Host == to.Host {
-		if path.IsAbs(from.Path) {
-			return &url.URL{Path: to.Path}
+	resolved := base.ResolveReference(from)
+
+	// The query of an external URL is part of what it points at, so it is
+	// left behind when the asset moves off its host, such as when vendored.
+	query := ""
+
+	if !resolved.IsAbs() || resolved.Scheme == to.Scheme && resolved.Host == to.Host {
+		query = from.RawQuery
+	}
+
+	if base.Scheme == to.Scheme && base.Host == to.Host {
+		if path.IsAbs(from.Path) && !from.IsAbs() {
+			return &url.URL{Path: to.Path, RawQuery: query, Fragment: from.Fragment}
 		}
 
 		path, _ := filepath.Rel(
@@ -40,8 +50,21 @@ func rewrite(base *url.URL, from *url.URL, to *url.URL) *url.URL {
 			filepath.FromSlash(to.Path),
 		)
 
-		return &url.URL{Path: filepath.ToSlash(path)}
+		return &url.URL{Path: filepath.ToSlash(path), RawQuery: query, Fragment: from.Fragment}
 	}
 
+	rewritten := *to
+	rewritten.RawQuery = query
+	rewritten.Fragment = from.Fragment
+
+	return &rewritten
+}
+
+// suffix copies the query and fragment of a reference onto a URL that the
+// reference is rewritten to, as these are not part of the path of the asset.
+func suffix(to *url.URL, from *url.URL) *url.URL {
+	to.RawQuery = from.RawQuery
+	to.Fragment = from.Fragment
+
 	return to
 }
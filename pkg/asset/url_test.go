@@ -25,6 +25,15 @@ func TestRebase(t *testing.T) {
 			&url.URL{Path: "/bar/baz.css"},
 		),
 	)
+
+	assert.Equal(t,
+		&url.URL{Path: "../img/icons.svg", Fragment: "foo"},
+		rebase(
+			&url.URL{Path: "../img/icons.svg", Fragment: "foo"},
+			&url.URL{Path: "/css/app.css"},
+			&url.URL{Path: "/styles/app.css"},
+		),
+	)
 }
 
 func TestRewrite(t *testing.T) {
@@ -36,4 +45,40 @@ func TestRewrite(t *testing.T) {
 			&url.URL{Path: "/bar/bar.css"},
 		),
 	)
+
+	assert.Equal(t,
+		&url.URL{Path: "/vendor/example.com/bar.css"},
+		rewrite(
+			&url.URL{Scheme: "http", Host: "example.com", Path: "/foo/foo.css"},
+			&url.URL{Path: "../bar.css"},
+			&url.URL{Path: "/vendor/example.com/bar.css"},
+		),
+	)
+
+	assert.Equal(t,
+		&url.URL{Path: "../img/icons.b12e0d83.svg", RawQuery: "v=1", Fragment: "foo"},
+		rewrite(
+			&url.URL{Path: "/css/app.css"},
+			&url.URL{Path: "../img/icons.svg", RawQuery: "v=1", Fragment: "foo"},
+			&url.URL{Path: "/img/icons.b12e0d83.svg"},
+		),
+	)
+
+	assert.Equal(t,
+		&url.URL{Path: "../vendor/fonts.example.com/css"},
+		rewrite(
+			&url.URL{Path: "/blog/index.html"},
+			&url.URL{Scheme: "https", Host: "fonts.example.com", Path: "/css", RawQuery: "family=A"},
+			&url.URL{Path: "/vendor/fonts.example.com/css"},
+		),
+	)
+
+	assert.Equal(t,
+		&url.URL{Scheme: "https", Host: "cdn.example.com", Path: "/img/icons.svg", Fragment: "foo"},
+		rewrite(
+			&url.URL{Path: "/css/app.css"},
+			&url.URL{Path: "../img/icons.svg", Fragment: "foo"},
+			&url.URL{Scheme: "https", Host: "cdn.example.com", Path: "/img/icons.svg"},
+		),
+	)
 }
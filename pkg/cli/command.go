@@ -22,7 +22,7 @@ type Command struct {
 	flag        flag.FlagSet
 	usage       string
 	parent      *Command
-	subcommands map[string]Command
+	subcommands map[string]*Command
 }
 
 func New(name string, usage string) *Command {
@@ -47,11 +47,11 @@ func (cmd *Command) HandleFunc(handler HandlerFunc) {
 
 func (cmd *Command) AddCommand(name string, help string, init func(*Command)) {
 	if cmd.subcommands == nil {
-		cmd.subcommands = make(map[string]Command)
+		cmd.subcommands = make(map[string]*Command)
 	}
 
-	command := Command{name: name, parent: cmd}
-	init(&command)
+	command := &Command{name: name, parent: cmd}
+	init(command)
 	cmd.subcommands[name] = command
 }
 
@@ -77,7 +77,28 @@ func (cmd *Command) Run(args []string) {
 	}
 }
 
+func (cmd *Command) Log(message string) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.invocation(), message)
+}
+
+func (cmd *Command) Logf(format string, args ...interface{}) {
+	cmd.Log(fmt.Sprintf(format, args...))
+}
+
+// Error prints an error and marks the command as failed. Errors that consist
+// of several errors, by way of an Errors() []error method, are printed one by
+// one.
 func (cmd *Command) Error(err error) {
+	if errs, ok := err.(interface{ Errors() []error }); ok {
+		for _, err := range errs.Errors() {
+			cmd.Error(err)
+		}
+
+		ExitCode(1)
+
+		return
+	}
+
 	fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.invocation(), err)
 	ExitCode(1)
 }
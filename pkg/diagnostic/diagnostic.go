@@ -0,0 +1,144 @@
+package diagnostic
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kasperisager/pak/pkg/lines"
+)
+
+// The number of lines to show before and after the offending line in a code
+// frame.
+const context = 2
+
+// A Diagnostic is a message about a location in a source. A diagnostic with a
+// zero line refers to the source as a whole.
+type Diagnostic struct {
+	URL     *url.URL
+	Offset  int
+	Line    int
+	Column  int
+	Message string
+	Frame   string
+}
+
+// From creates a diagnostic for the given rune offset in a source. Lines and
+// columns are 1-based and columns are counted in runes.
+func From(url *url.URL, runes []rune, offset int, message string) *Diagnostic {
+	source := lines.LinesFrom(runes)
+
+	if offset > len(runes) {
+		offset = len(runes)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	line, column := 0, offset
+
+	if len(source) > 0 {
+		line = source.Index(offset)
+		column = offset - source[line].Offset
+
+		// An offset at the very end of a source that ends in a line break is
+		// on a line of its own.
+		if column >= len(source[line].Value) && len(trim(source[line].Value)) < len(source[line].Value) {
+			source = append(source, lines.Line{Offset: len(runes)})
+			line, column = line+1, 0
+		}
+	}
+
+	return &Diagnostic{
+		URL:     url,
+		Offset:  offset,
+		Line:    line + 1,
+		Column:  column + 1,
+		Message: message,
+		Frame:   frame(source, line, column),
+	}
+}
+
+func (d *Diagnostic) Position() string {
+	if d.Line == 0 {
+		return d.URL.String()
+	}
+
+	return fmt.Sprintf("%s:%d:%d", d.URL, d.Line, d.Column)
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Frame == "" {
+		return fmt.Sprintf("%s: %s", d.Position(), d.Message)
+	}
+
+	return fmt.Sprintf("%s: %s\n\n%s", d.Position(), d.Message, d.Frame)
+}
+
+func frame(source lines.Lines, line int, column int) string {
+	if len(source) == 0 {
+		return ""
+	}
+
+	start, end := line-context, line+context
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end >= len(source) {
+		end = len(source) - 1
+	}
+
+	width := len(fmt.Sprint(end + 1))
+
+	var b strings.Builder
+
+	for i := start; i <= end; i++ {
+		marker := " "
+
+		if i == line {
+			marker = ">"
+		}
+
+		value := trim(source[i].Value)
+
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, i+1, string(value))
+
+		if i == line {
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", indent(value, column))
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func trim(value []rune) []rune {
+	for len(value) > 0 {
+		switch value[len(value)-1] {
+		case '\n', '\r':
+			value = value[:len(value)-1]
+		default:
+			return value
+		}
+	}
+
+	return value
+}
+
+// indent returns whitespace spanning the given number of runes of a line,
+// keeping tabs so that the caret lines up with the line above it.
+func indent(value []rune, column int) string {
+	var b strings.Builder
+
+	for i := 0; i < column; i++ {
+		if i < len(value) && value[i] == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+
+	return b.String()
+}
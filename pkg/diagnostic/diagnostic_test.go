@@ -0,0 +1,81 @@
+package diagnostic
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrom(t *testing.T) {
+	var tests = []struct {
+		source string
+		offset int
+		line   int
+		column int
+		frame  string
+	}{
+		{
+			"a{}",
+			1,
+			1, 2,
+			"> 1 | a{}\n" +
+				"    |  ^",
+		},
+		{
+			"a {\n  color: red\n}\n",
+			8,
+			2, 5,
+			"  1 | a {\n" +
+				"> 2 |   color: red\n" +
+				"    |     ^\n" +
+				"  3 | }",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11",
+			18,
+			10, 1,
+			"   8 | 8\n" +
+				"   9 | 9\n" +
+				"> 10 | 10\n" +
+				"     | ^\n" +
+				"  11 | 11",
+		},
+		{
+			"a {\r\n\tb\r\n",
+			6,
+			2, 2,
+			"  1 | a {\n" +
+				"> 2 | \tb\n" +
+				"    | \t^",
+		},
+		{
+			"a {\n",
+			4,
+			2, 1,
+			"  1 | a {\n" +
+				"> 2 | \n" +
+				"    | ^",
+		},
+		{
+			"",
+			0,
+			1, 1,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		diagnostic := From(&url.URL{Path: "/a.css"}, []rune(test.source), test.offset, "oops")
+
+		assert.Equal(t, test.line, diagnostic.Line, test.source)
+		assert.Equal(t, test.column, diagnostic.Column, test.source)
+		assert.Equal(t, test.frame, diagnostic.Frame, test.source)
+	}
+}
+
+func TestError(t *testing.T) {
+	diagnostic := From(&url.URL{Path: "/a.css"}, []rune("a }"), 2, "unexpected token")
+
+	assert.Equal(t, "/a.css:1:3: unexpected token\n\n> 1 | a }\n    |   ^", diagnostic.Error())
+}
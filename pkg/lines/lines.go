@@ -12,11 +12,15 @@ type Line struct {
 type Lines []Line
 
 func (lines Lines) At(offset int) Line {
+	return lines[lines.Index(offset)]
+}
+
+func (lines Lines) Index(offset int) int {
 	i := sort.Search(len(lines), func(i int) bool {
 		return lines[i].Offset > offset
 	})
 
-	return lines[i-1]
+	return i - 1
 }
 
 func LinesFrom(runes []rune) (lines Lines) {